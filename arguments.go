@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"fmt"
 	"html"
+	"maps"
 	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,18 +18,31 @@ import (
 
 // arguments structure
 type Args struct {
-	Nzblnk      string   `arg:"positional" help:"a qualified NZBLNK URI (nzblnk://?h=...)"`
-	Header      string   `arg:"-s,--subject" help:"the header/subject to search for"`
-	Title       string   `arg:"-t,--title" help:"the title/tag for the NZB file"`
-	Password    string   `arg:"-p,--password" help:"the password to extract the download"`
-	Groups      []string `arg:"-g,--group" help:"the group(s) to search in (several groups seperated with space)"`
-	Date        string   `arg:"-d,--date" help:"the date the upload was posted to Usenet (either in the format DD.MM.YYYY or as a Unix timestamp)"`
-	Category    string   `arg:"-c,--category" help:"the category to use for the target (if supportet by the target)"`
-	UnixDate    int64    `arg:"-"` // will hold the parsed Unix timestamp
-	IsTimestamp bool     `arg:"-"` // will indicate if exact timestamp was passed as date
-	Config      string   `arg:"--config" help:"path to the config file"`
-	Debug       bool     `arg:"--debug" help:"logs output to log file"`
-	Register    bool     `arg:"--register" help:"register the NZBLNK protocol"`
+	Nzblnk         string   `arg:"positional" help:"a qualified NZBLNK URI (nzblnk://?h=...)"`
+	Header         string   `arg:"-s,--subject" help:"the header/subject to search for"`
+	Title          string   `arg:"-t,--title" help:"the title/tag for the NZB file"`
+	Password       string   `arg:"-p,--password" help:"the password to extract the download"`
+	Groups         []string `arg:"-g,--group" help:"the group(s) to search in (several groups seperated with space)"`
+	Date           string   `arg:"-d,--date" help:"the date the upload was posted to Usenet (either in the format DD.MM.YYYY or as a Unix timestamp)"`
+	Category       string   `arg:"-c,--category" help:"the category to use for the target (if supportet by the target)"`
+	Flags          []string `arg:"-f,--flag,separate" help:"flag(s) to be used by the routing rules (can be used several times)"`
+	Priority       string   `arg:"--priority" help:"the priority of the job (default, low, normal, high, force or a number of the download client)"`
+	PostProcessing string   `arg:"--pp" help:"the post processing of the job (SABnzbd only: none, repair, unpack, delete)"`
+	Script         string   `arg:"--script" help:"the post processing script for the job"`
+	DupeKey        string   `arg:"--dupe-key" help:"the duplicate key of the job (NZBGet only)"`
+	DupeScore      string   `arg:"--dupe-score" help:"the duplicate score of the job (NZBGet only)"`
+	DupeMode       string   `arg:"--dupe-mode" help:"the duplicate mode of the job (NZBGet only: score, all, force)"`
+	PPParameters   []string `arg:"--pp-param,separate" help:"post processing parameter in the format name=value (NZBGet only, can be used several times)"`
+	UnixDate       int64    `arg:"-"` // will hold the parsed Unix timestamp
+	IsTimestamp    bool     `arg:"-"` // will indicate if exact timestamp was passed as date
+	Config         string   `arg:"--config" help:"path to the config file"`
+	Debug          bool     `arg:"--debug" help:"logs output to log file"`
+	LogLevel       string   `arg:"--log-level" help:"level of the messages written to the log file (debug, info, warn, error, off)"`
+	TraceHTTP      string   `arg:"--trace-http" placeholder:"DIR" help:"record all HTTP requests and responses (redacted) to the folder"`
+	Replay         string   `arg:"--replay" placeholder:"DIR" help:"use the HTTP responses recorded with --trace-http in the folder instead of the network"`
+	Force          bool     `arg:"--force" help:"push the NZB file even if it was already processed"`
+	Follow         bool     `arg:"--follow" help:"follow the download until it is completed or failed (SABnzbd and NZBGet only)"`
+	Register       bool     `arg:"--register" help:"register the NZBLNK protocol"`
 }
 
 // version information
@@ -37,7 +52,14 @@ func (Args) Version() string {
 
 // additional description
 func (Args) Epilogue() string {
-	return "   Parameters that are passed as arguments have precedence over the parameters of the NZBLNK.\n\n   For more information visit github.com/Tensai75/nzb-monkey-go\n"
+	epilogue := "   Parameters that are passed as arguments have precedence over the parameters of the NZBLNK.\n\n"
+	epilogue += "   Commands (use --help after a command for its arguments):\n"
+	names := slices.Sorted(maps.Keys(commands))
+	for _, name := range names {
+		epilogue += fmt.Sprintf("     %-18s %s\n", name, commands[name].description)
+	}
+	epilogue += "\n   For more information visit github.com/Tensai75/nzb-monkey-go\n"
+	return epilogue
 }
 
 // global arguments variable
@@ -50,6 +72,11 @@ var argParser *parser.Parser
 
 func parseArguments() {
 
+	// check for commands first
+	if parseCommand() {
+		return
+	}
+
 	parserConfig := parser.Config{
 		IgnoreEnv: true,
 	}
@@ -66,7 +93,7 @@ func parseArguments() {
 			exit(0)
 		}
 		writeUsage(argParser)
-		Log.Error(err.Error())
+		Log.Error("%s", err.Error())
 		exit(1)
 	}
 
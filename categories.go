@@ -2,81 +2,358 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/Tensai75/nzbparser"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 )
 
 type Categories []string
 
-func checkCategories() string {
+// categories selected in manual mode for each target (key is the name of the target instance)
+// they are used instead of the mapped category of the monkey
+var manualCategories map[string]string
 
-	if conf.General.Categorize == "auto" {
-		fmt.Println()
-		Log.Info("Automatic checking for categories ...")
-		for _, category := range conf.Categories {
-			if categoryRegexp, err := regexp.Compile("(?i)" + category.regex); err == nil {
-				if categoryRegexp.Match([]byte(args.Title)) {
-					Log.Info("Using category '%s'", category.name)
-					return category.name
-				}
+// categorizer rule structure
+// all conditions of a rule must match for the category to be used
+type CategoryRule struct {
+	name       string
+	title      *regexp.Regexp
+	header     *regexp.Regexp
+	group      *regexp.Regexp
+	poster     *regexp.Regexp
+	extensions []string
+	minSize    int64
+	maxSize    int64
+}
+
+var categoryConditionRegexp = regexp.MustCompile(`(?i)^\s*(title|header|group|poster|ext|min_size|max_size)\s*:`)
+
+// parses a categorizer rule
+// the rule is either a regex for the title or a list of conditions in the format "condition; condition; ..."
+// available conditions are "title:<regex>", "header:<regex>", "group:<regex>", "poster:<regex>", "ext:<ext>,<ext>", "min_size:<size>" and "max_size:<size>"
+func parseCategoryRule(name string, value string) (CategoryRule, error) {
+	rule := CategoryRule{name: name}
+	if !categoryConditionRegexp.MatchString(value) {
+		regex, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return rule, fmt.Errorf("invalid regex: %s", err.Error())
+		}
+		rule.title = regex
+		return rule, nil
+	}
+	for condition := range strings.SplitSeq(value, ";") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" {
+			continue
+		}
+		key, argument, _ := strings.Cut(condition, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		argument = strings.TrimSpace(argument)
+		switch key {
+		case "title", "header", "group", "poster":
+			regex, err := regexp.Compile("(?i)" + argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid regex: %s", err.Error())
+			}
+			switch key {
+			case "title":
+				rule.title = regex
+			case "header":
+				rule.header = regex
+			case "group":
+				rule.group = regex
+			case "poster":
+				rule.poster = regex
+			}
+		case "ext":
+			for _, extension := range splitList(argument) {
+				rule.extensions = append(rule.extensions, strings.ToLower(strings.TrimLeft(extension, ".")))
+			}
+		case "min_size", "max_size":
+			size, err := humanize.ParseBytes(argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid %s: %s", key, err.Error())
+			}
+			if key == "min_size" {
+				rule.minSize = int64(size)
 			} else {
-				Log.Warn("Error in the Regexp for '%s'", category.name)
+				rule.maxSize = int64(size)
 			}
+		default:
+			return rule, fmt.Errorf("unknown condition '%s'", key)
 		}
-		Log.Warn("No category did match")
-		return ""
 	}
+	return rule, nil
+}
 
-	if conf.General.Categorize == "manual" && targets[conf.General.Target].getCategories != nil {
-		fmt.Println()
-		Log.Info("Manual category selection")
-		Log.Info("Getting categories from %s ...", targets[conf.General.Target].name)
-		if categories, err := targets[conf.General.Target].getCategories(); err == nil {
-			if len(categories) > 0 {
-				fmt.Printf("   Please select category:\n")
-				color.Set(color.FgCyan)
-				for i, category := range categories {
-					fmt.Printf("             %d - %s\n", i+1, category)
-				}
-				fmt.Printf("             X - no category\n")
-				color.Unset()
-				input := 0
-				for input == 0 {
-					fmt.Print("   Enter the number of the category: ")
-					str := inputReader()
-					if str == "x" || str == "X" {
-						Log.Info("No category was selected")
-						return ""
-					}
-					input, err = strconv.Atoi(str)
-					if err != nil {
-						Log.Error("Not a number: %s", str)
-						continue
-					}
-					if input > 0 && input <= len(categories) {
-						Log.Info("Using category '%s'", categories[input-1])
-						return categories[input-1]
-					} else {
-						input = 0
-					}
+// returns true if all conditions of the rule match and the explanation why the rule did or did not match
+func (r *CategoryRule) match(result *Result) (bool, string) {
+	var reasons []string
+	var nzb *nzbparser.Nzb
+	if result != nil {
+		nzb = result.Nzb
+	}
+	if r.title != nil {
+		if !r.title.MatchString(args.Title) {
+			return false, fmt.Sprintf("title does not match '%s'", strings.TrimPrefix(r.title.String(), "(?i)"))
+		}
+		reasons = append(reasons, fmt.Sprintf("title matches '%s'", strings.TrimPrefix(r.title.String(), "(?i)")))
+	}
+	if r.header != nil {
+		if !r.header.MatchString(args.Header) {
+			return false, fmt.Sprintf("header does not match '%s'", strings.TrimPrefix(r.header.String(), "(?i)"))
+		}
+		reasons = append(reasons, fmt.Sprintf("header matches '%s'", strings.TrimPrefix(r.header.String(), "(?i)")))
+	}
+	if r.group != nil {
+		var groups []string
+		groups = append(groups, args.Groups...)
+		if nzb != nil {
+			for _, file := range nzb.Files {
+				groups = append(groups, file.Groups...)
+			}
+		}
+		index := slices.IndexFunc(groups, r.group.MatchString)
+		if index < 0 {
+			return false, fmt.Sprintf("no group matches '%s'", strings.TrimPrefix(r.group.String(), "(?i)"))
+		}
+		reasons = append(reasons, fmt.Sprintf("group '%s'", groups[index]))
+	}
+	if r.poster != nil {
+		var poster string
+		if nzb != nil {
+			if index := slices.IndexFunc(nzb.Files, func(file nzbparser.NzbFile) bool { return r.poster.MatchString(file.Poster) }); index >= 0 {
+				poster = nzb.Files[index].Poster
+			}
+		}
+		if poster == "" {
+			return false, fmt.Sprintf("no poster matches '%s'", strings.TrimPrefix(r.poster.String(), "(?i)"))
+		}
+		reasons = append(reasons, fmt.Sprintf("poster '%s'", poster))
+	}
+	if len(r.extensions) > 0 {
+		var name string
+		if nzb != nil {
+			for _, file := range nzb.Files {
+				if slices.Contains(r.extensions, strings.ToLower(strings.TrimPrefix(filepath.Ext(getFileName(file)), "."))) {
+					name = getFileName(file)
+					break
 				}
+			}
+		}
+		if name == "" {
+			return false, fmt.Sprintf("no file with the extension '%s'", strings.Join(r.extensions, "', '"))
+		}
+		reasons = append(reasons, fmt.Sprintf("file '%s'", name))
+	}
+	if r.minSize > 0 || r.maxSize > 0 {
+		var size int64
+		if nzb != nil {
+			size = nzb.Bytes
+		}
+		if r.minSize > 0 && size < r.minSize {
+			return false, fmt.Sprintf("size %s is less than %s", humanize.Bytes(uint64(size)), humanize.Bytes(uint64(r.minSize)))
+		}
+		if r.maxSize > 0 && size > r.maxSize {
+			return false, fmt.Sprintf("size %s is more than %s", humanize.Bytes(uint64(size)), humanize.Bytes(uint64(r.maxSize)))
+		}
+		reasons = append(reasons, fmt.Sprintf("size %s", humanize.Bytes(uint64(size))))
+	}
+	if len(reasons) == 0 {
+		return true, "no conditions"
+	}
+	return true, strings.Join(reasons, ", ")
+}
+
+// function to choose the category with the categorizer rules
+// if explain is true the result of every rule is logged
+// returns the category and the explanation which rule matched
+func categorize(result *Result, explain bool) (string, string) {
+	for _, rule := range conf.Categories {
+		matched, reason := rule.match(result)
+		if explain {
+			if matched {
+				Log.Info("- %s: %s (%s)", rule.name, green("matched"), reason)
 			} else {
-				Log.Warn("%s returned no categories", targets[conf.General.Target].name)
+				Log.Info("- %s: %s (%s)", rule.name, red("no match"), reason)
 			}
+		}
+		if matched {
+			return rule.name, fmt.Sprintf("rule '%s' (%s)", rule.name, reason)
+		}
+	}
+	if conf.DefaultCategory != "" {
+		return conf.DefaultCategory, "default category"
+	}
+	return "", ""
+}
+
+func checkCategories(result *Result) string {
+
+	manualCategories = nil
+
+	// a category provided as argument has precedence
+	if args.Category != "" {
+		return args.Category
+	}
+
+	if conf.General.Categorize == "auto" {
+		fmt.Println()
+		Log.Info("Automatic checking for categories ...")
+		category, reason := categorize(result, false)
+		if category == "" {
+			Log.Warn("No category did match")
 		} else {
-			Log.Error("Unable to get categories: %s", err.Error())
+			Log.Info("Using category '%s' - %s", category, reason)
 		}
+		return category
+	}
+
+	if conf.General.Categorize == "manual" {
+		// the categories are selected for the targets the nzb file will be pushed to
+		// routing rules with categories can only match after the category was selected
+		pushTargets, _, _ := resolveTargets(result, "")
+		return selectCategories(pushTargets)
 	}
 	return ""
 }
 
+// function to let the user select the categories of the targets supporting categories
+// targets with an already selected category are skipped
+// returns the category selected for the first target
+func selectCategories(targetNames []string) string {
+
+	if manualCategories == nil {
+		manualCategories = make(map[string]string)
+	}
+	var categoryTargets []string
+	for _, target := range targetNames {
+		if _, selected := manualCategories[target]; !selected && targets[target].getCategories != nil {
+			categoryTargets = append(categoryTargets, target)
+		}
+	}
+	if len(categoryTargets) == 0 {
+		return ""
+	}
+
+	// the merged category which was already selected is used for the further targets
+	if conf.General.ManualCategories == "merged" {
+		for _, category := range manualCategories {
+			for _, target := range categoryTargets {
+				manualCategories[target] = category
+			}
+			return category
+		}
+	}
+
+	fmt.Println()
+	Log.Info("Manual category selection")
+
+	if conf.General.ManualCategories == "merged" {
+		var merged Categories
+		for _, target := range categoryTargets {
+			for _, category := range getTargetCategories(target) {
+				if !slices.Contains(merged, category) {
+					merged = append(merged, category)
+				}
+			}
+		}
+		if len(merged) == 0 {
+			return ""
+		}
+		category := promptCategory(merged)
+		for _, target := range categoryTargets {
+			manualCategories[target] = category
+		}
+		return category
+	}
+
+	var first string
+	var selected bool
+	for _, target := range categoryTargets {
+		categories := getTargetCategories(target)
+		if len(categories) == 0 {
+			continue
+		}
+		if len(categoryTargets) > 1 {
+			Log.Info("Category for %s:", targets[target].name)
+		}
+		category := promptCategory(categories)
+		manualCategories[target] = category
+		if !selected {
+			first, selected = category, true
+		}
+	}
+	return first
+
+}
+
+// returns the categories of a target or nil if the target returned no categories
+func getTargetCategories(target string) Categories {
+	Log.Info("Getting categories from %s ...", targets[target].name)
+	categories, err := targets[target].getCategories()
+	if err != nil {
+		Log.Error("Unable to get categories: %s", err.Error())
+		return nil
+	}
+	if len(categories) == 0 {
+		Log.Warn("%s returned no categories", targets[target].name)
+	}
+	return categories
+}
+
+// function to let the user select a category from the list
+func promptCategory(categories Categories) string {
+	fmt.Printf("   Please select category:\n")
+	color.Set(color.FgCyan)
+	for i, category := range categories {
+		fmt.Printf("             %d - %s\n", i+1, category)
+	}
+	fmt.Printf("             X - no category\n")
+	color.Unset()
+	for {
+		fmt.Print("   Enter the number of the category: ")
+		str := inputReader()
+		if str == "x" || str == "X" {
+			Log.Info("No category was selected")
+			return ""
+		}
+		input, err := strconv.Atoi(str)
+		if err != nil {
+			Log.Error("Not a number: %s", str)
+			continue
+		}
+		if input > 0 && input <= len(categories) {
+			Log.Info("Using category '%s'", categories[input-1])
+			return categories[input-1]
+		}
+	}
+}
+
+// parses a category mapping in the format "category:target category, category:target category"
+func parseCategoryMap(value string) (map[string]string, error) {
+	categoryMap := make(map[string]string)
+	for _, item := range splitList(value) {
+		category, mapped, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(category) == "" {
+			return categoryMap, fmt.Errorf("invalid mapping '%s'", item)
+		}
+		categoryMap[strings.ToLower(strings.TrimSpace(category))] = strings.TrimSpace(mapped)
+	}
+	return categoryMap, nil
+}
+
 func inputReader() string {
 	reader := bufio.NewReader(os.Stdin)
 	input, err := reader.ReadString('\n')
@@ -84,8 +361,98 @@ func inputReader() string {
 		if errors.Is(err, io.EOF) { // prefered way by GoLang doc
 			os.Exit(0)
 		}
-		Log.Warn("An error occurred while reading input. Please try again", err)
+		Log.Warn("An error occurred while reading input. Please try again: %s", err.Error())
 		return ""
 	}
 	return strings.TrimSpace(input)
 }
+
+// arguments of the categorize command
+type CategorizeArgs struct {
+	CommonArgs
+	Explain bool     `arg:"--explain" help:"show for each rule if and why it matched"`
+	Title   string   `arg:"-t,--title" help:"the title to categorize"`
+	Header  string   `arg:"-s,--subject" help:"the header/subject to categorize"`
+	Groups  []string `arg:"-g,--group,separate" help:"the group(s) of the post (can be used several times)"`
+	Nzb     string   `arg:"--nzb" help:"path to a NZB file for the conditions on the files"`
+	History int      `arg:"--history" help:"use title, header, groups and the stored NZB file of a history entry"`
+}
+
+var categorizeArgs CategorizeArgs
+
+// function to run the categorize command
+// categorizes the given post with the categorizer rules without pushing anything
+func categorizeCommand() {
+
+	var result *Result
+	args.Title = categorizeArgs.Title
+	args.Header = categorizeArgs.Header
+	args.Groups = categorizeArgs.Groups
+
+	nzbPath := categorizeArgs.Nzb
+	if categorizeArgs.History > 0 {
+		entries, err := loadHistory()
+		if err != nil {
+			Log.Error("Unable to load history: %s", err.Error())
+			exit(1)
+		}
+		entry := findHistoryEntry(entries, categorizeArgs.History)
+		args.Title = cmp.Or(args.Title, entry.Title)
+		args.Header = cmp.Or(args.Header, entry.Header)
+		if len(args.Groups) == 0 {
+			args.Groups = entry.Groups
+		}
+		if nzbPath == "" {
+			if _, err := os.Stat(historyNzbPath(entry.ID)); err == nil {
+				nzbPath = historyNzbPath(entry.ID)
+			}
+		}
+	}
+	if nzbPath != "" {
+		nzbfile, err := os.ReadFile(nzbPath)
+		if err != nil {
+			Log.Error("Unable to read NZB file: %s", err.Error())
+			exit(1)
+		}
+		nzb, err := nzbparser.ParseString(string(nzbfile))
+		if err != nil {
+			Log.Error("Unable to parse NZB file: %s", err.Error())
+			exit(1)
+		}
+		if args.Title == "" {
+			args.Title = cmp.Or(nzb.Meta["title"], strings.TrimSuffix(filepath.Base(nzbPath), filepath.Ext(nzbPath)))
+		}
+		result = &Result{Nzb: nzb}
+	}
+	if args.Title == "" && args.Header == "" && result == nil {
+		writeUsage(commandParser)
+		Log.Error("Missing title, header, NZB file or history entry")
+		exit(1)
+	}
+
+	fmt.Println()
+	Log.Info("Title:    %s", blue(args.Title))
+	if args.Header != "" {
+		Log.Info("Header:   %s", blue(args.Header))
+	}
+	if len(args.Groups) > 0 {
+		Log.Info("Groups:   %s", blue(strings.Join(args.Groups, ", ")))
+	}
+	if result != nil {
+		Log.Info("NZB file: %s", blue(fmt.Sprintf("%d files, %s", result.Nzb.Files.Len(), humanize.Bytes(uint64(result.Nzb.Bytes)))))
+	}
+	fmt.Println()
+	if categorizeArgs.Explain {
+		Log.Info("Checking the categorizer rules ...")
+	}
+	category, reason := categorize(result, categorizeArgs.Explain)
+	if categorizeArgs.Explain {
+		fmt.Println()
+	}
+	if category == "" {
+		Log.Warn("No category did match")
+		return
+	}
+	Log.Succ("Category '%s' - %s", category, reason)
+
+}
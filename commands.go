@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	parser "github.com/alexflint/go-arg"
+)
+
+// command structure
+// commands are called as the first argument, e.g. "nzb-monkey-go history list"
+type Command struct {
+	description string
+	args        CommandArgs
+	run         func()
+}
+
+// arguments of a command must provide the common options
+type CommandArgs interface {
+	common() *CommonArgs
+}
+
+// common options for all commands
+type CommonArgs struct {
+	Config    string `arg:"--config" help:"path to the config file"`
+	Debug     bool   `arg:"--debug" help:"logs output to log file"`
+	LogLevel  string `arg:"--log-level" help:"level of the messages written to the log file (debug, info, warn, error, off)"`
+	TraceHTTP string `arg:"--trace-http" placeholder:"DIR" help:"record all HTTP requests and responses (redacted) to the folder"`
+	Replay    string `arg:"--replay" placeholder:"DIR" help:"use the HTTP responses recorded with --trace-http in the folder instead of the network"`
+}
+
+func (c *CommonArgs) common() *CommonArgs {
+	return c
+}
+
+// commands map
+type Commands map[string]Command
+
+// global commands map
+var commands = Commands{
+	"categorize": Command{
+		description: "test the categorizer rules without pushing anything",
+		args:        &categorizeArgs,
+		run:         categorizeCommand,
+	},
+	"history": Command{
+		description: "list, search, show or push entries of the history",
+		args:        &historyArgs,
+		run:         historyCommand,
+	},
+	"spool": Command{
+		description: "list or flush the pushes spooled because a target was unreachable",
+		args:        &spoolArgs,
+		run:         spoolCommand,
+	},
+}
+
+// the command to run (nil if the monkey was called with a NZBLNK or a header)
+var command *Command
+
+// parser of the command
+var commandParser *parser.Parser
+
+// program will not wait before ending when a command was run
+var noWait bool
+
+func parseCommand() bool {
+
+	if len(os.Args) < 2 {
+		return false
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		return false
+	}
+
+	parserConfig := parser.Config{
+		Program:   fmt.Sprintf("%s %s", filepath.Base(os.Args[0]), os.Args[1]),
+		IgnoreEnv: true,
+	}
+
+	var err error
+	noWait = true
+	if commandParser, err = parser.NewParser(parserConfig, cmd.args); err != nil {
+		Log.Error("%s", err.Error())
+		exit(1)
+	}
+	if err := commandParser.Parse(os.Args[2:]); err != nil {
+		if err == parser.ErrHelp {
+			writeHelp(commandParser)
+			exit(0)
+		}
+		writeUsage(commandParser)
+		Log.Error("%s", err.Error())
+		exit(1)
+	}
+
+	// pass the common options to the global arguments
+	args.Config = cmd.args.common().Config
+	args.Debug = cmd.args.common().Debug
+	args.LogLevel = cmd.args.common().LogLevel
+	args.TraceHTTP = cmd.args.common().TraceHTTP
+	args.Replay = cmd.args.common().Replay
+
+	command = &cmd
+	return true
+
+}
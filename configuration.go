@@ -1,6 +1,7 @@
 package main
 
 import (
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,51 +13,76 @@ type General struct {
 	Target            string `ini:"target"`
 	Targets           []string
 	Categorize        string `ini:"categorize"`
+	ManualCategories  string `ini:"manual_categories"`
 	Success_wait_time int    `ini:"success_wait_time"`
 	Error_wait_time   int    `ini:"error_wait_time"`
 	Debug             bool   `ini:"debug"`
+	TargetMode        string `ini:"target_mode"`
+	Retries           int    `ini:"retries"`
+	RetryDelay        int    `ini:"retry_delay"`
+	RetryPost         bool   `ini:"retry_post"`
+	FollowInterval    int    `ini:"follow_interval"`
+	FollowTimeout     int    `ini:"follow_timeout"`
 }
 
 type Execute struct {
-	Passtofile      bool   `ini:"passtofile"`
-	Passtoclipboard bool   `ini:"passtoclipboard"`
-	Nzbsavepath     string `ini:"nzbsavepath"`
-	Category_folder bool   `ini:"category_folder"`
-	Dontexecute     bool   `ini:"dontexecute"`
-	SaveAsZip       bool   `ini:"save_as_zip"`
-	CleanUpEnable   bool   `ini:"clean_up_enable"`
-	CleanUpMaxAge   int    `ini:"clean_up_max_age"`
+	Name             string `ini:"-"` // display name of the target instance
+	Passtofile       bool   `ini:"passtofile"`
+	Passtoclipboard  bool   `ini:"passtoclipboard"`
+	Nzbsavepath      string `ini:"nzbsavepath"`
+	Category_folder  bool   `ini:"category_folder"`
+	Dontexecute      bool   `ini:"dontexecute"`
+	SaveAsZip        bool   `ini:"save_as_zip"`
+	CleanUpEnable    bool   `ini:"clean_up_enable"`
+	CleanUpMaxAge    int    `ini:"clean_up_max_age"`
+	FilenameTemplate string `ini:"filename_template"`
+	FolderTemplate   string `ini:"folder_template"`
+	OnCollision      string `ini:"on_collision"`
 }
 
 type SABnzbd struct {
-	Host              string `ini:"host"`
-	Port              int    `ini:"port"`
-	Ssl               bool   `ini:"ssl"`
-	SkipCheck         bool   `ini:"skip_check"`
-	Nzbkey            string `ini:"nzbkey"`
-	BasicauthUsername string `ini:"basicauth_username"`
-	BasicauthPassword string `ini:"basicauth_password"`
-	Basepath          string `ini:"basepath"`
-	Category          string `ini:"category"`
-	Addpaused         bool   `ini:"addpaused"`
-	Compression       string `ini:"compression"`
-	Timeout           int    `ini:"timeout"`
+	Name              string     `ini:"-"` // display name of the target instance
+	Host              string     `ini:"host"`
+	Port              int        `ini:"port"`
+	Ssl               bool       `ini:"ssl"`
+	SkipCheck         bool       `ini:"skip_check"`
+	Nzbkey            string     `ini:"nzbkey"`
+	BasicauthUsername string     `ini:"basicauth_username"`
+	BasicauthPassword string     `ini:"basicauth_password"`
+	Basepath          string     `ini:"basepath"`
+	Category          string     `ini:"category"`
+	Addpaused         bool       `ini:"addpaused"`
+	Compression       string     `ini:"compression"`
+	Timeout           int        `ini:"timeout"`
+	Retries           int        `ini:"retries"`
+	RetryDelay        int        `ini:"retry_delay"`
+	RetryPost         bool       `ini:"retry_post"`
+	Verify            bool       `ini:"verify"`
+	JobOptions        JobOptions `ini:"-"` // will hold the job options of the section
 }
 
 type NZBGet struct {
-	Host              string `ini:"host"`
-	Port              int    `ini:"port"`
-	Ssl               bool   `ini:"ssl"`
-	SkipCheck         bool   `ini:"skip_check"`
-	BasicauthUsername string `ini:"user"`
-	BasicauthPassword string `ini:"pass"`
-	Basepath          string `ini:"basepath"`
-	Category          string `ini:"category"`
-	Addpaused         bool   `ini:"addpaused"`
-	Timeout           int    `ini:"timeout"`
+	Name              string     `ini:"-"` // display name of the target instance
+	Host              string     `ini:"host"`
+	Port              int        `ini:"port"`
+	Ssl               bool       `ini:"ssl"`
+	SkipCheck         bool       `ini:"skip_check"`
+	BasicauthUsername string     `ini:"user"`
+	BasicauthPassword string     `ini:"pass"`
+	Basepath          string     `ini:"basepath"`
+	Category          string     `ini:"category"`
+	Addpaused         bool       `ini:"addpaused"`
+	Timeout           int        `ini:"timeout"`
+	Retries           int        `ini:"retries"`
+	RetryDelay        int        `ini:"retry_delay"`
+	RetryPost         bool       `ini:"retry_post"`
+	Verify            bool       `ini:"verify"`
+	JobOptions        JobOptions `ini:"-"` // will hold the job options of the section
+	AddToTop          bool       `ini:"add_to_top"`
 }
 
 type SynologyDS struct {
+	Name              string `ini:"-"` // display name of the target instance
 	Host              string `ini:"host"`
 	Port              int    `ini:"port"`
 	Ssl               bool   `ini:"ssl"`
@@ -67,6 +93,80 @@ type SynologyDS struct {
 	BasicauthPassword string
 	Basepath          string `ini:"basepath"`
 	Timeout           int    `ini:"timeout"`
+	Retries           int    `ini:"retries"`
+	RetryDelay        int    `ini:"retry_delay"`
+	RetryPost         bool   `ini:"retry_post"`
+	Destination       string `ini:"destination"`
+	UseCategory       bool   `ini:"use_category"`
+	Logout            bool   `ini:"logout"`
+	OtpSecret         string `ini:"otp_secret"`
+}
+
+type Download struct {
+	Name           string `ini:"-"` // display name of the target instance
+	Destination    string `ini:"destination"`
+	CategoryFolder bool   `ini:"category_folder"`
+}
+
+type Webhook struct {
+	Name              string            `ini:"-"` // display name of the target instance
+	URL               string            `ini:"url"`
+	Method            string            `ini:"method"`
+	SkipCheck         bool              `ini:"skip_check"`
+	BasicauthUsername string            `ini:"basicauth_username"`
+	BasicauthPassword string            `ini:"basicauth_password"`
+	BearerToken       string            `ini:"bearer_token"`
+	Headers           map[string]string `ini:"-"` // will hold the header.<name> keys
+	Body              string            `ini:"body"`
+	FileField         string            `ini:"file_field"`
+	Template          string            `ini:"template"`
+	TemplateFile      string            `ini:"template_file"`
+	ContentType       string            `ini:"content_type"`
+	SuccessStatus     string            `ini:"success_status"`
+	SuccessJSON       string            `ini:"success_json"`
+	Timeout           int               `ini:"timeout"`
+	Retries           int               `ini:"retries"`
+	RetryDelay        int               `ini:"retry_delay"`
+	RetryPost         bool              `ini:"retry_post"`
+}
+
+type DesktopNotifier struct {
+	Name    string `ini:"-"` // display name of the notifier
+	Timeout int    `ini:"timeout"`
+}
+
+type HTTPNotifier struct {
+	Name    string            `ini:"-"` // display name of the notifier
+	URL     string            `ini:"url"`
+	Format  string            `ini:"format"`
+	Token   string            `ini:"token"`
+	Headers map[string]string `ini:"-"` // will hold the header.<name> keys
+}
+
+type SMTPNotifier struct {
+	Name      string `ini:"-"` // display name of the notifier
+	Host      string `ini:"host"`
+	Port      int    `ini:"port"`
+	Ssl       bool   `ini:"ssl"`
+	SkipCheck bool   `ini:"skip_check"`
+	Username  string `ini:"username"`
+	Password  string `ini:"password"`
+	From      string `ini:"from"`
+	To        string `ini:"to"`
+	Timeout   int    `ini:"timeout"`
+}
+
+type MQTTNotifier struct {
+	Name      string `ini:"-"` // display name of the notifier
+	Broker    string `ini:"broker"`
+	Topic     string `ini:"topic"`
+	Username  string `ini:"username"`
+	Password  string `ini:"password"`
+	ClientID  string `ini:"client_id"`
+	Qos       int    `ini:"qos"`
+	Retain    bool   `ini:"retain"`
+	SkipCheck bool   `ini:"skip_check"`
+	Timeout   int    `ini:"timeout"`
 }
 
 type NZBcheck struct {
@@ -74,11 +174,23 @@ type NZBcheck struct {
 	MaxMissingSegmentsPercent float64 `ini:"max_missing_segments_percent"`
 	MaxMissingFiles           int     `ini:"max_missing_files"`
 	BestNZB                   bool    `ini:"best_nzb"`
+	Merge                     bool    `ini:"merge"`
+	VerifyAvailability        bool    `ini:"verify_availability"`
+	VerifySamplePercent       int     `ini:"verify_sample_percent"`
+	Par2Check                 bool    `ini:"par2_check"`
+	Deobfuscate               bool    `ini:"deobfuscate"`
 }
 
-type CategorySettings struct {
-	name  string
-	regex string
+type History struct {
+	Enable     bool   `ini:"enable"`
+	Duplicates string `ini:"duplicates"`
+	StoreNzb   bool   `ini:"store_nzb"`
+}
+
+type Spool struct {
+	Enable    bool `ini:"enable"`
+	AutoFlush bool `ini:"auto_flush"`
+	MaxAge    int  `ini:"max_age"`
 }
 
 type Easynews struct {
@@ -110,17 +222,39 @@ type DirectSearch struct {
 }
 
 // configuration structure
+type LogConfig struct {
+	Level      string `ini:"level"`
+	File       string `ini:"file"`
+	Format     string `ini:"format"`
+	MaxSize    int    `ini:"max_size"`
+	MaxAge     int    `ini:"max_age"`
+	MaxBackups int    `ini:"max_backups"`
+}
+
+type Hooks struct {
+	PreSearch string `ini:"pre_search"`
+	PostFound string `ini:"post_found"`
+	PrePush   string `ini:"pre_push"`
+	PostPush  string `ini:"post_push"`
+	Timeout   int    `ini:"timeout"`
+}
+
 type Configuration struct {
-	General       General            `ini:"GENERAL"`
-	Execute       Execute            `ini:"EXECUTE"`
-	Sabnzbd       SABnzbd            `ini:"SABNZBD"`
-	Nzbget        NZBGet             `ini:"NZBGET"`
-	Synologyds    SynologyDS         `ini:"SYNOLOGYDLS"`
-	Nzbcheck      NZBcheck           `ini:"NZBCheck"`
-	Categories    []CategorySettings `ini:"-"` // will hold the categories regex patterns
-	Searchengines []string           `ini:"-"` // will hold the search engines
-	Easynews      Easynews           `ini:"EASYNEWS"`
-	Directsearch  DirectSearch       `ini:"DIRECTSEARCH"`
+	General            General                 `ini:"GENERAL"`
+	Nzbcheck           NZBcheck                `ini:"NZBCheck"`
+	History            History                 `ini:"HISTORY"`
+	Spool              Spool                   `ini:"SPOOL"`
+	Hooks              Hooks                   `ini:"HOOKS"`
+	Log                LogConfig               `ini:"LOG"`
+	Categories         []CategoryRule          `ini:"-"` // will hold the categorizer rules
+	DefaultCategory    string                  `ini:"-"` // will hold the category used if no categorizer rule matched
+	CategoryJobOptions map[string]JobOptions   `ini:"-"` // will hold the job options per category
+	Filters            map[string][]FilterRule `ini:"-"` // will hold the file filter rules per category ("" = global rules)
+	Searchengines      []string                `ini:"-"` // will hold the search engines
+	Routing            []RoutingRule           `ini:"-"` // will hold the routing rules
+	RoutingFallback    []string                `ini:"-"` // will hold the fallback targets of the routing rules
+	Easynews           Easynews                `ini:"EASYNEWS"`
+	Directsearch       DirectSearch            `ini:"DIRECTSEARCH"`
 }
 
 // global configuration variable
@@ -131,6 +265,34 @@ var (
 func loadConfig() {
 
 	conf = Configuration{
+		General: General{
+			TargetMode:       "all",
+			RetryDelay:       2,
+			FollowInterval:   10,
+			FollowTimeout:    1440,
+			ManualCategories: "separate",
+		},
+		Nzbcheck: NZBcheck{
+			VerifySamplePercent: 100,
+		},
+		History: History{
+			Enable:     true,
+			Duplicates: "warn",
+			StoreNzb:   true,
+		},
+		Spool: Spool{
+			Enable:    true,
+			AutoFlush: true,
+			MaxAge:    7,
+		},
+		Hooks: Hooks{
+			Timeout: 30,
+		},
+		Log: LogConfig{
+			Format:     "text",
+			MaxSize:    10,
+			MaxBackups: 3,
+		},
 		Directsearch: DirectSearch{
 			Connections:                20,
 			Hours:                      12,
@@ -166,13 +328,54 @@ func loadConfig() {
 	// load categories
 	if cfg.HasSection("CATEGORIZER") {
 		for _, key := range cfg.Section("CATEGORIZER").Keys() {
-			conf.Categories = append(conf.Categories, struct {
-				name  string
-				regex string
-			}{name: key.Name(), regex: key.Value()})
+			if key.Name() == "default" {
+				conf.DefaultCategory = strings.TrimSpace(key.Value())
+				continue
+			}
+			if rule, err := parseCategoryRule(key.Name(), key.Value()); err == nil {
+				conf.Categories = append(conf.Categories, rule)
+			} else {
+				Log.Warn("Error in the categorizer rule '%s': %s", key.Name(), err.Error())
+			}
+		}
+	}
+
+	// load job options per category
+	conf.CategoryJobOptions = make(map[string]JobOptions)
+	for _, section := range cfg.Sections() {
+		if name, ok := strings.CutPrefix(section.Name(), "CATEGORY:"); ok && name != "" {
+			var options JobOptions
+			if err := section.MapTo(&options); err != nil {
+				Log.Warn("Error in the job options in section '%s': %s", section.Name(), err.Error())
+				continue
+			}
+			conf.CategoryJobOptions[strings.ToLower(name)] = options
+		}
+	}
+
+	// load file filter rules
+	conf.Filters = make(map[string][]FilterRule)
+	for _, section := range cfg.Sections() {
+		var category string
+		if section.Name() == "FILTER" {
+			category = ""
+		} else if name, ok := strings.CutPrefix(section.Name(), "FILTER:"); ok && name != "" {
+			category = name
+		} else {
+			continue
+		}
+		for _, key := range section.Keys() {
+			if rule, err := parseFilterRule(key.Name(), key.Value()); err == nil {
+				conf.Filters[category] = append(conf.Filters[category], rule)
+			} else {
+				Log.Warn("Error in the filter rule '%s' in section '%s': %s", key.Name(), section.Name(), err.Error())
+			}
 		}
 	}
 
+	// load search engine plugins
+	loadPlugins(cfg)
+
 	// load searchengines
 	searchengines := make(map[string]int)
 	if cfg.HasSection("SEARCHENGINES") {
@@ -210,11 +413,34 @@ func loadConfig() {
 		conf.Searchengines = engines
 	}
 
-	// check debug parameter
-	if !args.Debug {
-		args.Debug = conf.General.Debug
+	// configure the logger with the log settings
+	addConfigSecrets(cfg)
+	Log.reconfigure()
+
+	// check target mode parameter
+	if !slices.Contains([]string{"all", "failover", "first_success"}, conf.General.TargetMode) {
+		Log.Warn("Unknown target mode '%s'. Using 'all'", conf.General.TargetMode)
+		conf.General.TargetMode = "all"
+	}
+
+	// check manual categories parameter
+	if !slices.Contains([]string{"separate", "merged"}, conf.General.ManualCategories) {
+		Log.Warn("Unknown manual categories mode '%s'. Using 'separate'", conf.General.ManualCategories)
+		conf.General.ManualCategories = "separate"
+	}
+
+	// check history duplicates parameter
+	if !slices.Contains([]string{"off", "warn", "skip"}, conf.History.Duplicates) {
+		Log.Warn("Unknown history duplicates mode '%s'. Using 'warn'", conf.History.Duplicates)
+		conf.History.Duplicates = "warn"
 	}
 
+	// load target instances
+	loadTargets(cfg)
+
+	// load notifiers
+	loadNotifiers(cfg)
+
 	// check target parameter
 	for target := range strings.SplitSeq(conf.General.Target, ",") {
 		target = strings.TrimSpace(target)
@@ -228,4 +454,19 @@ func loadConfig() {
 		Log.Error("Configuration error: no valid targets")
 		exit(1)
 	}
+
+	// load routing rules
+	if cfg.HasSection("ROUTING") {
+		for _, key := range cfg.Section("ROUTING").Keys() {
+			if key.Name() == "fallback" {
+				conf.RoutingFallback = parseTargetList(key.Value())
+				continue
+			}
+			if rule, err := parseRoutingRule(key.Name(), key.Value()); err == nil {
+				conf.Routing = append(conf.Routing, rule)
+			} else {
+				Log.Warn("Error in the routing rule '%s': %s", key.Name(), err.Error())
+			}
+		}
+	}
 }
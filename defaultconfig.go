@@ -5,16 +5,39 @@ import "strings"
 func defaultConfig() string {
 	return strings.Trim(`
 [GENERAL]
-# Target for handling nzb files - EXECUTE, SABNZBD, NZBGET or SYNOLOGYDLS
+# Target for handling nzb files - EXECUTE, SABNZBD, NZBGET, SYNOLOGYDLS, WEBHOOK, DOWNLOAD or the name of a target instance
 # Multiple targets can be separated by commas, e.g. "EXECUTE,SABNZBD"
+# Additional instances of a target type can be defined in sections [TARGET:<name>] with a "type" key
+# and the same settings as the section of the target type, e.g. [TARGET:sab-seedbox] with type = "SABNZBD"
 target = "EXECUTE"
+# How to push to several targets. Values are:
+# all = push to all targets (default), failover = push to the targets in the given order and stop at the first successful push,
+# first_success = push to all targets but only report an error if no push was successful
+target_mode = "all"
+# Number of retries for transient errors (timeouts, refused connections and server errors) when pushing to a target
+# Certificate errors, unknown hosts and invalid URLs are not retried
+# Can be overwritten in the section of each target
+retries = 0
+# Seconds to wait before the first retry (doubled for each further retry)
+retry_delay = 2
+# Also retry POST requests (e.g. adding the NZB file) which failed after they were sent
+# The target may already have added the NZB file so this can result in duplicate downloads (default = false)
+retry_post = false
+# Seconds between the status requests when following the download with --follow (SABnzbd and NZBGet only)
+follow_interval = 10
+# Minutes after which the following of the download is stopped with an error (0 = no limit)
+# Status requests failing with a temporary error (e.g. a timeout or a restart of the target) are repeated
+follow_timeout = 1440
 # Let the monkey choose a category. Values are: off, auto, manual
 categorize = "off"
+# How the categories are offered in manual mode if several targets support categories. Values are:
+# separate = select a category for each target from its own list, merged = select one category from the merged lists of all targets
+manual_categories = "separate"
 # Seconds to wait befor ending/closing the window after success
 success_wait_time = 3
 # Seconds to wait befor ending/closing the window after an error
 error_wait_time = 10
-# Write debug log to logfile.txt on windows/osx (same dir as nzb-monkey-go) or /tmp/nzb-monkey-go.log on linux
+# Write debug log to the log file (same as level = "debug" in the [LOG] section)
 debug = false
 
 [EXECUTE]
@@ -27,6 +50,8 @@ passtoclipboard = false
 nzbsavepath = "./Downloads/nzb"
 # Use category subfolders
 category_folder = false
+# Map the categories of the monkey to other subfolder names, e.g. "series:TV Shows, movies:Movies"
+category_map = ""
 # Don't execute default programm for .nzb
 dontexecute = true
 # Save nzb files as compressed zip files
@@ -35,6 +60,15 @@ save_as_zip = false
 clean_up_enable = false
 # NZB files older than x days will be deleted
 clean_up_max_age = 2
+# Template for the file name (without extension). Leave empty to use the title (and {{password}} if passtofile is enabled)
+# Available placeholders: {title}, {header}, {password}, {category}, {engine}, {size},
+# {date:<layout>} (current date) and {postdate:<layout>} (date of the post) with a Go time layout, e.g. {date:2006-01-02}
+# Example: "{title} [{category}]{{{password}}}"
+filename_template = ""
+# Template for the subfolders of nzbsavepath (overrides category_folder), e.g. "{category}/{date:2006-01}"
+folder_template = ""
+# What to do if the file already exists. Values are: overwrite, counter, skip
+on_collision = "overwrite"
 
 [SABNZBD]
 # SABnzbd Hostname
@@ -55,10 +89,20 @@ basicauth_password = ""
 basepath = ""
 # Category
 category = ""
+# Map the categories of the monkey to the categories of SABnzbd, e.g. "series:tv, movies:movies-hd"
+category_map = ""
 # Add the nzb paused to the queue
 addpaused = false
 # Add compression on upload, either "none" or "zip"
 compression = "none"
+# Priority of the job: default, low, normal, high, force or a number (e.g. -1)
+priority = "default"
+# Post processing of the job: none, repair, unpack, delete (empty = default of the category)
+pp = ""
+# Post processing script of the job (empty = default of the category)
+script = ""
+# Check the queue after the push if the job was added with the correct name, category and password
+verify = true
 
 [NZBGET]
 # NZBGet Host
@@ -77,8 +121,24 @@ pass = ""
 basepath = ""
 # NZBGet Category
 category = ""
+# Map the categories of the monkey to the categories of NZBGet, e.g. "series:tv, movies:movies-hd"
+category_map = ""
 # Add the nzb paused to the queue
 addpaused = false
+# Add the nzb to the top of the queue
+add_to_top = false
+# Priority of the job: very_low, low, normal, high, very_high, force or a number (e.g. -50)
+priority = "normal"
+# Post processing script(s) of the job, separated by commas (empty = default of the category)
+script = ""
+# Duplicate key, score and mode (score, all, force) of the job
+dupe_key = ""
+dupe_score = 0
+dupe_mode = "all"
+# Additional post processing parameters in the format "name=value, name=value"
+pp_parameters = ""
+# Check the queue after the push if the job was added with the correct name, category and password
+verify = true
 
 [SYNOLOGYDLS]
 # Downloadstation Host
@@ -95,6 +155,108 @@ user = ""
 pass = ""
 # Basepath
 basepath = ""
+# Destination folder for the downloads, e.g. "downloads/usenet" (empty = default folder of the Downloadstation)
+destination = ""
+# Use the category as destination folder (the shared folders are offered as categories in manual mode)
+# Use category_map to map the categories to folders, e.g. "series:video/tv, movies:video/movies"
+use_category = false
+category_map = ""
+# Log out after each push (otherwise the session is reused)
+logout = false
+# Secret of the 2-factor authentication (TOTP) to create the codes automatically
+# If empty and a code is required, the code will be requested
+otp_secret = ""
+
+[DOWNLOAD]
+# Download the files with the built-in downloader (uses the usenet server of the DIRECTSEARCH section)
+# par2 repair and unpacking are not supported
+# Path to save the downloaded files (a subfolder with the title is created)
+# Either an absolute path or a path relative to the user's home directory
+destination = "./Downloads/usenet"
+# Use category subfolders
+category_folder = false
+
+# Send the NZB file to your own HTTP endpoint
+# Please uncomment the following lines and use "WEBHOOK" as target
+# [WEBHOOK]
+# URL of the endpoint
+# url = "https://localhost:8000/nzb"
+# HTTP method
+# method = "POST"
+# skip SSL security checks (e.g. for self signed certificates)
+# skip_check = false
+# Authentication with basic auth or a bearer token
+# basicauth_username = ""
+# basicauth_password = ""
+# bearer_token = ""
+# Additional headers in the format header.<name> = "<value>"
+# header.X-Api-Key = ""
+# Body of the request. Values are:
+# json = JSON object with title, header, password, category, groups, engine, filename and the base64 encoded nzb
+# multipart = form with the fields title, header, password, category, groups and the NZB file in the field file_field
+# template = the Go template in template or template_file with the same fields as the JSON object
+#            (.Title, .Header, .Password, .Category, .Groups, .Engine, .Filename, .Nzb, .NzbBase64)
+#            and the functions json and base64, e.g. {"name": {{json .Title}}, "data": {{json .NzbBase64}}}
+# body = "json"
+# file_field = "nzbfile"
+# template = ""
+# template_file = ""
+# content_type = "application/json"
+# Response status codes which are a success
+# success_status = "200,201,202,204"
+# Value in the JSON response which must match for a success in the format "path.to.value = value", e.g. "status = ok"
+# success_json = ""
+# timeout = 30
+
+# Notifications about the outcome of a job
+# Notifiers are defined in sections [NOTIFIER:<name>] with a "type" key. Types are:
+# desktop = desktop notification on Linux (org.freedesktop.Notifications via D-Bus)
+# http    = HTTP notification service (format ntfy, gotify, apprise or json)
+# smtp    = e-mail
+# mqtt    = MQTT message (the notification as JSON object)
+# The events to notify are set with "events" (default all). Events are:
+# found = the NZB file was pushed, incomplete = the NZB file is probably incomplete,
+# not_found = no NZB file was found, push_failed = the push to a target failed
+# Please uncomment the following lines
+# [NOTIFIER:desktop]
+# type = "desktop"
+# events = "incomplete, not_found, push_failed"
+# Time in seconds the notification is shown (-1 = default of the desktop, 0 = until closed)
+# timeout = -1
+#
+# [NOTIFIER:phone]
+# type = "http"
+# URL of the topic (ntfy), the server (gotify), the notify endpoint (apprise) or the endpoint (json)
+# url = "https://ntfy.sh/my-nzb-monkey"
+# format = "ntfy"
+# Access token (sent as bearer token or as X-Gotify-Key for gotify)
+# token = ""
+# Additional headers in the format header.<name> = "<value>"
+#
+# [NOTIFIER:mail]
+# type = "smtp"
+# host = "smtp.example.com"
+# Port of the server (STARTTLS is used if available) or 465 with ssl = true
+# port = 587
+# ssl = false
+# skip_check = false
+# username = ""
+# password = ""
+# from = "nzb-monkey@example.com"
+# Comma separated list of recipients
+# to = "me@example.com"
+#
+# [NOTIFIER:homeassistant]
+# type = "mqtt"
+# Broker as tcp://host:port or ssl://host:port
+# broker = "tcp://localhost:1883"
+# Topic of the message, {event} is replaced with the event
+# topic = "nzb-monkey-go/{event}"
+# username = ""
+# password = ""
+# client_id = "nzb-monkey-go"
+# qos = 0
+# retain = false
 
 [NZBCheck]
 # Don't skip failed nzb
@@ -105,12 +267,139 @@ max_missing_segments_percent = 1
 max_missing_files = 1
 # Use always all Searchengines to find the best NZB but stop once a NZB with 100% completeness has been found.
 best_nzb = true
+# Merge incomplete NZB files of the same post found by different search engines into one more complete NZB file
+# NZB files are of the same post if they have the same posters and the same file subjects
+merge = false
+# Verify the availability of the articles on the news server (settings for the nzb direct search required)
+# The verified availability is used for the completeness check and to choose the best NZB file
+verify_availability = false
+# Percentage of the articles of each file to verify (1-100, default = 100)
+verify_sample_percent = 100
+# Estimate if the missing segments can be repaired with the available par2 recovery blocks
+# If par2 files are found, "repairable / not repairable" is used instead of the max missing thresholds
+# Missing par2 volumes are detected with the file numbers of the subjects ([i/n]) or, if the availability
+# is verified, with the file list of the par2 index file read from the news server
+par2_check = false
+# Recover the real filenames of obfuscated posts before the NZB file is pushed
+# The first article of each file and the par2 file are downloaded (uses the usenet server of the DIRECTSEARCH section)
+deobfuscate = false
+
+[HISTORY]
+# Record the processed NZB files in the history file (same dir as the configuration file)
+enable = true
+# Check for duplicates (same header or same NZB file) before pushing. Values are:
+# off = no check, warn = only show a warning, skip = do not push duplicates (unless --force is used)
+duplicates = "warn"
+# Store the NZB files in the history so they can be pushed again with "nzb-monkey-go history push <id>"
+store_nzb = true
+
+[SPOOL]
+# Save the NZB file in the spool folder (same dir as the configuration file) if a target is unreachable
+# (connection errors, timeouts and server errors, other errors like an invalid API key are not spooled)
+# The job options (priority, post processing, ...) of the failed push are kept for the retry
+# The spooled pushes can be retried with "nzb-monkey-go spool flush"
+enable = true
+# Retry the spooled pushes automatically at the start of every run
+auto_flush = true
+# Spooled pushes older than x days will be deleted (0 = never)
+max_age = 7
+
+[LOG]
+# Level of the messages written to the log file: debug, info, warn, error or off (can be set with --log-level)
+# If not set the log file is only written with --debug or debug = true
+level = ""
+# Path of the log file (default logfile.txt on windows/osx in the same dir as nzb-monkey-go or /tmp/nzb-monkey-go.log on linux)
+file = ""
+# Format of the lines: text or json (one JSON object per line with time, level, run and msg)
+# Every line contains the id of the run to tell the lines of concurrent instances apart
+format = "text"
+# The log file is rotated if it is larger than x MB (0 = no size limit) ...
+max_size = 10
+# ... or if it was started more than x days ago (0 = no age limit)
+max_age = 0
+# Number of rotated log files to keep (nzb-monkey-go.log.1, nzb-monkey-go.log.2, ...)
+max_backups = 3
+# Passwords, API keys, tokens, session ids and the password of the NZB file are replaced with [redacted]
+# on the console, in the log file, the HTTP traces and the notifications, so they can be attached to bug reports
+# Values shorter than 4 characters are only redacted after their label (e.g. "Password: ..." or apikey=...)
+
+[HOOKS]
+# Commands to run at defined points of the processing (executed with "sh -c" or "cmd /C" on Windows)
+# The job context is passed as JSON on stdin and as NZBMONKEY_* environment variables
+# (e.g. NZBMONKEY_TITLE, NZBMONKEY_HEADER, NZBMONKEY_CATEGORY, NZBMONKEY_NZB_FILE)
+# Lines in the format "key=value" written to stdout are used as described below
+# pre_search: runs before the search, can set title, header, password, groups and category
+#             a non-zero exit code aborts the search
+pre_search = ""
+# post_found: runs for every NZB file found, a non-zero exit code skips the NZB file
+post_found = ""
+# pre_push:   runs before the push, can modify the NZB file (NZBMONKEY_NZB_FILE) and set the category
+#             a non-zero exit code aborts the push
+pre_push = ""
+# post_push:  runs after the push, the pushed and failed targets are passed (e.g. for notifications)
+post_push = ""
+# Hooks running longer than x seconds are terminated and ignored
+timeout = 30
 
 [CATEGORIZER]
 # Place your category and you regex here
+# The rules are checked in the given order and the category of the first matching rule is used
+# Instead of a regex for the title a rule can combine conditions in the format "condition; condition; ..."
+# The rule matches if all conditions match. Available conditions:
+#   title:<regex>      the title matches the regex (case insensitive)
+#   header:<regex>     the header matches the regex (case insensitive)
+#   group:<regex>      one of the groups matches the regex (case insensitive)
+#   poster:<regex>     the poster of one of the files matches the regex (case insensitive)
+#   ext:<ext>,<ext>    the NZB file contains a file with one of the extensions
+#   min_size:<size>    the total size is at least this size (e.g. 20GB)
+#   max_size:<size>    the total size is at most this size (e.g. 20GB)
+# The category "default" is used if no rule matched
+# Use "nzb-monkey-go categorize --explain" to test the rules
 # Please uncomment the following lines
+# movies-uhd = "ext:mkv; min_size:20GB"
 # series = "(s\d+e\d+|s\d+ complete)"
 # movies = "(x264|xvid|bluray|720p|1080p|untouched)"
+# default = "misc"
+
+# Job options for a category can be placed in a section [CATEGORY:<name>]
+# They overwrite the job options of the SABNZBD and NZBGET sections
+# Available options: priority, pp, script, dupe_key, dupe_score, dupe_mode and pp_parameters
+# The options passed as arguments (e.g. --priority high) have precedence
+# Please uncomment the following lines
+# [CATEGORY:series]
+# script = "tv.py"
+# priority = "high"
+
+[FILTER]
+# Remove files from the NZB file before it is pushed to the target
+# Place your rules here in the format: name = "condition; condition; ..."
+# A file is removed if all conditions of a rule match. Available conditions:
+#   regex:<regex>      the filename matches the regex (case insensitive)
+#   ext:<ext>,<ext>    the file extension is one of the listed extensions
+#   min_size:<size>    the file is at least this size (e.g. 10MB)
+#   max_size:<size>    the file is at most this size (e.g. 10MB)
+#   complete           the post is complete (e.g. to remove the par2 volumes)
+# Rules for a specific category can be placed in a section [FILTER:<category>]
+# Please uncomment the following lines
+# sample = "regex:\bsample\b"
+# extras = "ext:nfo,sfv,jpg"
+# par2 = "regex:\.vol\d+\+\d+\.par2$; complete"
+
+[ROUTING]
+# Push the NZB file to different targets based on rules instead of all targets of the GENERAL section
+# Place your rules here in the format: name = "condition; condition; ... => target, target"
+# The rules are checked in the given order and the first rule with all conditions matching is used. Available conditions:
+#   category:<name>,<name>   the category is one of the listed categories
+#   title:<regex>            the title matches the regex (case insensitive)
+#   group:<regex>            one of the groups matches the regex (case insensitive)
+#   min_size:<size>          the NZB file is at least this size (e.g. 50GB)
+#   max_size:<size>          the NZB file is at most this size (e.g. 50GB)
+#   flag:<name>,<name>       all listed flags were passed with --flag on the command line
+# If no rule matches, the targets of "fallback" are used (or the targets of the GENERAL section if not set)
+# Please uncomment the following lines
+# series = "category:series => NZBGET"
+# large = "min_size:50GB => SYNOLOGYDLS"
+# fallback = "SABNZBD"
 
 [SEARCHENGINES]
 # Set values between 0-9
@@ -126,6 +415,27 @@ binsearch = 3
 easynews = 4
 # Enable nzb direct search (settings for the nzb direct search required)
 directsearch = 5
+# Search engine plugins defined in a [PLUGIN:<name>] section are enabled with their name
+# myindexer = 6
+
+# Search engine plugin (external program)
+# The program receives the query as JSON on stdin:
+#   {"header": "...", "title": "...", "password": "...", "groups": ["..."], "date": 0, "category": "..."}
+# and writes one JSON object per line to stdout:
+#   {"nzb": "<?xml ..."}  a candidate NZB file as inline xml
+#   {"file": "/path/file.nzb"}  a candidate NZB file as path
+#   {"log": "message", "level": "info"}  a log message (debug, info, warn or error)
+# Other lines are shown as log messages. A non-zero exit code is reported as an error.
+# The program runs in the directory of this configuration file, so relative paths of the command
+# and of the returned files are relative to this directory
+# Please uncomment the following lines
+# [PLUGIN:myindexer]
+# Command line of the program (executed with "sh -c" or "cmd /C" on Windows)
+# command = "/usr/local/bin/myindexer-search"
+# Display name of the search engine
+# name = "My Indexer"
+# The program is terminated after x seconds
+# timeout = 60
 
 # Settings for the Easynews search
 [EASYNEWS]
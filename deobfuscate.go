@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tensai75/nzbparser"
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+// larger par2 files are not downloaded
+var par2MaxSize = int64(10 * 1024 * 1024)
+
+// function to recover the real filenames of obfuscated posts
+// the names are taken from the par2 file descriptions or from the yEnc headers of the first articles
+// and the subjects of the files are rewritten with the real names
+func deobfuscateNzb(result *Result) {
+
+	fmt.Println()
+	Log.Info("Recovering the real filenames ...")
+
+	if conf.Directsearch.Username == "" || conf.Directsearch.Password == "" {
+		Log.Warn("Unable to recover the filenames: no or incomplete credentials for usenet server")
+		return
+	}
+	owned, err := initNntpPool()
+	if err != nil {
+		Log.Warn("Unable to recover the filenames: %s", err.Error())
+		return
+	}
+	if owned {
+		defer closeNntpPool()
+	}
+
+	// fetch the first article of each file
+	var ids []string
+	for _, file := range result.Nzb.Files {
+		id := ""
+		if file.Segments.Len() > 0 {
+			id = slices.MinFunc(file.Segments, func(a, b nzbparser.NzbSegment) int { return a.Number - b.Number }).Id
+		}
+		ids = append(ids, id)
+	}
+	parts, err := fetchSegments(ids, "   Fetching ... ")
+	if err != nil {
+		Log.Warn("Unable to recover the filenames: %s", err.Error())
+		return
+	}
+
+	// the names of the yEnc headers to find the par2 file
+	yencNames := make([]string, len(parts))
+	for i, part := range parts {
+		if part != nil {
+			yencNames[i] = part.Name
+		}
+	}
+
+	index, err := readPar2Index(result.Nzb, yencNames)
+	if err != nil {
+		Log.Warn("Unable to read the par2 file: %s", err.Error())
+	}
+	par2Names := make(map[[16]byte]string)
+	if index != nil {
+		result.Par2Index = index
+		for _, file := range index.Files {
+			par2Names[file.Hash16k] = file.Name
+		}
+	}
+
+	var renamed int
+	for i := range result.Nzb.Files {
+		file := &result.Nzb.Files[i]
+		name, source := realFileName(parts[i], par2Names)
+		if name == "" || name == getFileName(*file) {
+			continue
+		}
+		Log.Info("- '%s' => '%s' (%s)", getFileName(*file), name, source)
+		file.Subject = fmt.Sprintf("[%d/%d] - \"%s\" yEnc (1/%d)", i+1, result.Nzb.Files.Len(), name, file.TotalSegments)
+		file.Filename = name
+		file.Basefilename = strings.TrimSuffix(name, filepath.Ext(name))
+		renamed++
+	}
+	if renamed == 0 {
+		Log.Info("The filenames are not obfuscated")
+	} else {
+		Log.Succ("Recovered the filenames of %d files", renamed)
+	}
+
+}
+
+// returns the real filename of a file and its source from the first article of the file
+// the par2 name is identified by the hash of the first 16 kB and preferred over the name of the yEnc header
+func realFileName(part *yencPart, par2Names map[[16]byte]string) (string, string) {
+	if part == nil {
+		return "", ""
+	}
+	name, source := part.Name, "yEnc"
+	if part.Begin == 1 && (len(part.Data) >= par2HashBlockSize || part.End == part.Size) {
+		if par2Name := par2Names[md5.Sum(part.Data[:min(par2HashBlockSize, len(part.Data))])]; par2Name != "" {
+			name, source = par2Name, "par2"
+		}
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "" || name == "." {
+		return "", ""
+	}
+	return name, source
+}
+
+// fetches and decodes the articles using all configured connections
+// the parts of missing or damaged articles are nil
+func fetchSegments(ids []string, description string) ([]*yencPart, error) {
+
+	bar := progressbar.NewOptions(len(ids),
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetRenderBlankState(true),
+		progressbar.OptionThrottle(time.Millisecond*100),
+		progressbar.OptionShowElapsedTimeOnFinish(),
+		progressbar.OptionShowCount(),
+		progressbar.OptionUseANSICodes(conf.Directsearch.UseANSICodes),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	parts := make([]*yencPart, len(ids))
+	indexChan := make(chan int)
+	var mutex sync.Mutex
+	var firstError error
+
+	var wg sync.WaitGroup
+	for range max(conf.Directsearch.Connections, 1) {
+		wg.Go(func() {
+			for index := range indexChan {
+				if ids[index] != "" {
+					part, err := download_segment(ctx, ids[index])
+					mutex.Lock()
+					if err == nil {
+						parts[index] = part
+					} else if !errors.Is(err, errArticleNotFound) && !errors.Is(err, errArticleDamaged) && firstError == nil {
+						firstError = err
+						cancel()
+					}
+					mutex.Unlock()
+				}
+				bar.Add(1)
+			}
+		})
+	}
+
+feed:
+	for index := range ids {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexChan <- index:
+		}
+	}
+	close(indexChan)
+	wg.Wait()
+	bar.Finish()
+	fmt.Println()
+
+	if firstError != nil {
+		return nil, firstError
+	}
+	return parts, nil
+}
+
+// downloads the smallest par2 file of the post and returns its par2 index
+// returns nil if the post has no par2 file or the par2 file is too large
+func readPar2Index(nzb *nzbparser.Nzb, yencNames []string) (*par2Index, error) {
+
+	// find the smallest par2 file
+	index := -1
+	for i, file := range nzb.Files {
+		name := getFileName(file)
+		if i < len(yencNames) && yencNames[i] != "" {
+			name = yencNames[i]
+		}
+		if par2IndexRegexp.MatchString(name) && (index < 0 || file.Bytes < nzb.Files[index].Bytes) {
+			index = i
+		}
+	}
+	if index < 0 || nzb.Files[index].Bytes > par2MaxSize {
+		return nil, nil
+	}
+
+	var ids []string
+	for _, segment := range nzb.Files[index].Segments {
+		ids = append(ids, segment.Id)
+	}
+	parts, err := fetchSegments(ids, "   Reading par2 ... ")
+	if err != nil {
+		return nil, err
+	}
+
+	// assemble the par2 file
+	var data []byte
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		if end := int(part.Begin-1) + len(part.Data); end > len(data) {
+			data = append(data, make([]byte, end-len(data))...)
+		}
+		copy(data[part.Begin-1:], part.Data)
+	}
+
+	return parsePar2Index(data), nil
+
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"testing"
+)
+
+func TestRealFileName(t *testing.T) {
+
+	large := bytes.Repeat([]byte("data"), par2HashBlockSize/2)
+	small := []byte("small file")
+	par2Names := map[[16]byte]string{
+		md5.Sum(large[:par2HashBlockSize]): "movie.part1.rar",
+		md5.Sum(small):                     "movie.nfo",
+	}
+	first := func(name string, data []byte, size int64) *yencPart {
+		return &yencPart{Name: name, Size: size, Begin: 1, End: int64(len(data)), Data: data}
+	}
+
+	tests := []struct {
+		name   string
+		part   *yencPart
+		want   string
+		source string
+	}{
+		{"par2 name of a large file", first("a8f3c2e1", large, 10*int64(len(large))), "movie.part1.rar", "par2"},
+		{"par2 name of a small file", first("b7d9e0f2", small, int64(len(small))), "movie.nfo", "par2"},
+		{"yEnc name without par2 name", first("movie.part2.rar", []byte("other data"), 10), "movie.part2.rar", "yEnc"},
+		{"first article smaller than 16 kB", first("c1d2e3f4.bin", small, 100), "c1d2e3f4.bin", "yEnc"},
+		{"not the first article", &yencPart{Name: "d5e6f7a8", Size: 100, Begin: 11, End: 20, Data: small}, "d5e6f7a8", "yEnc"},
+		{"path in the yEnc name", first(" folder/movie.sfv ", []byte("other data"), 10), "movie.sfv", "yEnc"},
+		{"empty yEnc name", first("", []byte("other data"), 10), "", ""},
+		{"missing article", nil, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, source := realFileName(test.part, par2Names)
+			if name != test.want || source != test.source {
+				t.Errorf("got %q (%s), want %q (%s)", name, source, test.want, test.source)
+			}
+		})
+	}
+
+}
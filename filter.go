@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Tensai75/nzbparser"
+	humanize "github.com/dustin/go-humanize"
+)
+
+// file filter rule structure
+// all conditions of a rule must match for a file to be removed
+type FilterRule struct {
+	name         string
+	regex        *regexp.Regexp
+	extensions   []string
+	minSize      int64
+	maxSize      int64
+	completeOnly bool
+}
+
+// parses a filter rule in the format "regex:<regex>; ext:<ext>,<ext>; min_size:<size>; max_size:<size>; complete"
+func parseFilterRule(name string, value string) (FilterRule, error) {
+	rule := FilterRule{name: name}
+	for condition := range strings.SplitSeq(value, ";") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" {
+			continue
+		}
+		key, argument, _ := strings.Cut(condition, ":")
+		argument = strings.TrimSpace(argument)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "regex":
+			regex, err := regexp.Compile("(?i)" + argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid regex: %s", err.Error())
+			}
+			rule.regex = regex
+		case "ext":
+			for extension := range strings.SplitSeq(argument, ",") {
+				extension = strings.ToLower(strings.TrimLeft(strings.TrimSpace(extension), "."))
+				if extension != "" {
+					rule.extensions = append(rule.extensions, extension)
+				}
+			}
+		case "min_size":
+			size, err := humanize.ParseBytes(argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid min_size: %s", err.Error())
+			}
+			rule.minSize = int64(size)
+		case "max_size":
+			size, err := humanize.ParseBytes(argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid max_size: %s", err.Error())
+			}
+			rule.maxSize = int64(size)
+		case "complete":
+			rule.completeOnly = true
+		default:
+			return rule, fmt.Errorf("unknown condition '%s'", key)
+		}
+	}
+	if rule.regex == nil && len(rule.extensions) == 0 && rule.minSize == 0 && rule.maxSize == 0 {
+		return rule, fmt.Errorf("no condition defined")
+	}
+	return rule, nil
+}
+
+// returns true if the file matches all conditions of the rule
+func (r *FilterRule) match(file nzbparser.NzbFile, complete bool) bool {
+	name := getFileName(file)
+	if r.completeOnly && !complete {
+		return false
+	}
+	if r.regex != nil && !r.regex.MatchString(name) {
+		return false
+	}
+	if len(r.extensions) > 0 && !slices.Contains(r.extensions, strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))) {
+		return false
+	}
+	if r.minSize > 0 && file.Bytes < r.minSize {
+		return false
+	}
+	if r.maxSize > 0 && file.Bytes > r.maxSize {
+		return false
+	}
+	return true
+}
+
+// function to remove the files matching the global filter rules and the filter rules of the category
+func filterFiles(result *Result, category string) {
+
+	rules := conf.Filters[""]
+	if category != "" {
+		rules = append(slices.Clone(rules), conf.Filters[category]...)
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	complete := result.FilesMissing == 0 && result.SegmentsMissing == 0
+	if result.Par2Checked {
+		complete = result.DamagedBlocks == 0
+	}
+
+	var files nzbparser.NzbFiles
+	var removed []string
+	for _, file := range result.Nzb.Files {
+		matched := ""
+		for _, rule := range rules {
+			if rule.match(file, complete) {
+				matched = rule.name
+				break
+			}
+		}
+		if matched != "" {
+			removed = append(removed, fmt.Sprintf("'%s' (%s) - rule '%s'", getFileName(file), humanize.Bytes(uint64(file.Bytes)), matched))
+		} else {
+			files = append(files, file)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+
+	fmt.Println()
+	if len(files) == 0 {
+		Log.Warn("The filter rules would remove all files. Filtering is skipped.")
+		return
+	}
+	Log.Info("Removing %d files from the NZB file:", len(removed))
+	for _, line := range removed {
+		Log.Info("- %s", line)
+	}
+
+	result.Nzb.TotalFiles -= len(result.Nzb.Files) - len(files)
+	result.Nzb.Files = files
+	updateNzbTotals(result.Nzb)
+
+}
+
+// updates the totals of the nzb file after files were removed
+func updateNzbTotals(nzb *nzbparser.Nzb) {
+	nzb.Segments = 0
+	nzb.TotalSegments = 0
+	nzb.Bytes = 0
+	for _, file := range nzb.Files {
+		nzb.Segments += file.Segments.Len()
+		nzb.TotalSegments += file.TotalSegments
+		nzb.Bytes += file.Bytes
+	}
+	if nzb.TotalFiles < nzb.Files.Len() {
+		nzb.TotalFiles = nzb.Files.Len()
+	}
+}
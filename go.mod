@@ -4,6 +4,7 @@ go 1.25.0
 
 require (
 	github.com/Tensai75/fslock v0.0.0-20160525022230-4d5c94c67b4b
+	github.com/Tensai75/nntp v0.1.5
 	github.com/Tensai75/nntpDirectSearch v0.2.2
 	github.com/Tensai75/nntpPool v0.1.3
 	github.com/Tensai75/nzbparser v0.1.0
@@ -21,7 +22,6 @@ require (
 )
 
 require (
-	github.com/Tensai75/nntp v0.1.5 // indirect
 	github.com/Tensai75/subjectparser v0.1.1 // indirect
 	github.com/alexflint/go-scalar v1.2.0 // indirect
 	github.com/juju/fslock v0.0.0-20160525022230-4d5c94c67b4b // indirect
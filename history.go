@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tensai75/fslock"
+	"github.com/Tensai75/nzbparser"
+)
+
+// history entry structure
+type HistoryEntry struct {
+	ID       int       `json:"id"`
+	Header   string    `json:"header"`
+	Title    string    `json:"title"`
+	Password string    `json:"password,omitempty"`
+	Groups   []string  `json:"groups,omitempty"`
+	PostDate int64     `json:"post_date,omitempty"`
+	Engine   string    `json:"engine"`
+	Hash     string    `json:"hash"`
+	Category string    `json:"category,omitempty"`
+	Targets  []string  `json:"targets"`
+	Route    string    `json:"route,omitempty"`
+	Added    time.Time `json:"added"`
+}
+
+// returns the path of the history file which is stored next to the configuration file
+func historyPath() string {
+	return filepath.Join(filepath.Dir(confPath), "nzb-monkey-go.history")
+}
+
+// returns a hash over the sorted message-ids of the nzb file
+func nzbHash(nzb *nzbparser.Nzb) string {
+	var ids []string
+	for _, file := range nzb.Files {
+		for _, segment := range file.Segments {
+			ids = append(ids, segment.Id)
+		}
+	}
+	slices.Sort(ids)
+	hash := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// loads all entries of the history file
+func loadHistory() ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	file, err := os.Open(historyPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			Log.Debug("Invalid history entry: %s", err.Error())
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// returns the path of the folder where the nzb files of the history are stored
+func historyNzbPath(id int) string {
+	return filepath.Join(filepath.Dir(confPath), "nzb-monkey-go-history", fmt.Sprintf("%d.nzb", id))
+}
+
+// appends an entry to the history file and returns the id of the new entry
+// the history file is locked so concurrent instances do not use the same id
+func addHistoryEntry(entry HistoryEntry) (int, error) {
+	lock := fslock.New(historyPath() + ".lock")
+	if err := lock.Lock(); err != nil {
+		return 0, err
+	}
+	defer lock.Unlock()
+	entries, err := loadHistory()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if e.ID >= entry.ID {
+			entry.ID = e.ID + 1
+		}
+	}
+	if entry.ID == 0 {
+		entry.ID = 1
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return entry.ID, err
+}
+
+// function to check if the same header or the same message-ids were already processed
+// returns false if the nzb file should be skipped
+func checkHistory(nzb *nzbparser.Nzb) bool {
+
+	if !conf.History.Enable || conf.History.Duplicates == "off" {
+		return true
+	}
+
+	entries, err := loadHistory()
+	if err != nil {
+		Log.Warn("Unable to load history: %s", err.Error())
+		return true
+	}
+
+	hash := nzbHash(nzb)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		var reason string
+		if entry.Hash == hash {
+			reason = "The same NZB file"
+		} else if strings.EqualFold(strings.TrimSpace(entry.Header), strings.TrimSpace(args.Header)) {
+			reason = "The same header"
+		} else {
+			continue
+		}
+		fmt.Println()
+		Log.Warn("%s was already pushed on %s to %s (history id %d)", reason, entry.Added.Local().Format("02.01.2006 15:04:05"), strings.Join(entry.Targets, ", "), entry.ID)
+		if conf.History.Duplicates == "skip" && !args.Force {
+			Log.Warn("The NZB file is skipped because it is a duplicate (use --force to push it anyway)")
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+// function to add the processed nzb file to the history
+func addToHistory(result *Result, nzbfile string, category string, pushedTargets []string, route string) {
+
+	if !conf.History.Enable {
+		return
+	}
+
+	entry := HistoryEntry{
+		Header:   args.Header,
+		Title:    args.Title,
+		Password: args.Password,
+		Groups:   args.Groups,
+		PostDate: args.UnixDate,
+		Engine:   result.SearchEngine,
+		Hash:     nzbHash(result.Nzb),
+		Category: category,
+		Targets:  pushedTargets,
+		Route:    route,
+		Added:    time.Now(),
+	}
+	id, err := addHistoryEntry(entry)
+	if err != nil {
+		Log.Warn("Unable to write history: %s", err.Error())
+		return
+	}
+	if conf.History.StoreNzb {
+		storeHistoryNzb(id, nzbfile)
+	}
+
+}
+
+// stores the nzb file of a history entry so it can be pushed again later
+func storeHistoryNzb(id int, nzbfile string) {
+	path := historyNzbPath(id)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		Log.Warn("Unable to store NZB file in history: %s", err.Error())
+		return
+	}
+	if err := os.WriteFile(path, []byte(nzbfile), 0600); err != nil {
+		Log.Warn("Unable to store NZB file in history: %s", err.Error())
+	}
+}
+
+// arguments of the history command
+type HistoryArgs struct {
+	CommonArgs
+	List   *struct{}          `arg:"subcommand:list" help:"list all entries of the history"`
+	Search *HistorySearchArgs `arg:"subcommand:search" help:"search the history for a term"`
+	Show   *HistoryShowArgs   `arg:"subcommand:show" help:"show all details of a history entry"`
+	Push   *HistoryPushArgs   `arg:"subcommand:push" help:"push the stored NZB file of a history entry again"`
+}
+
+type HistorySearchArgs struct {
+	Term string `arg:"positional,required" help:"the term to search for in header, title, password and category"`
+}
+
+type HistoryShowArgs struct {
+	ID int `arg:"positional,required" help:"the id of the history entry"`
+}
+
+type HistoryPushArgs struct {
+	ID       int      `arg:"positional,required" help:"the id of the history entry"`
+	Targets  []string `arg:"--target,separate" help:"the target to push to (can be used several times, default = configured targets)"`
+	Category string   `arg:"-c,--category" help:"the category to use instead of the stored category"`
+}
+
+var historyArgs HistoryArgs
+
+// function to run the history command
+func historyCommand() {
+
+	entries, err := loadHistory()
+	if err != nil {
+		Log.Error("Unable to load history: %s", err.Error())
+		exit(1)
+	}
+
+	fmt.Println()
+	switch {
+	case historyArgs.List != nil:
+		listHistory(entries)
+	case historyArgs.Search != nil:
+		term := strings.ToLower(historyArgs.Search.Term)
+		var found []HistoryEntry
+		for _, entry := range entries {
+			for _, value := range []string{entry.Header, entry.Title, entry.Password, entry.Category} {
+				if strings.Contains(strings.ToLower(value), term) {
+					found = append(found, entry)
+					break
+				}
+			}
+		}
+		listHistory(found)
+	case historyArgs.Show != nil:
+		showHistoryEntry(findHistoryEntry(entries, historyArgs.Show.ID))
+	case historyArgs.Push != nil:
+		pushHistoryEntry(findHistoryEntry(entries, historyArgs.Push.ID), historyArgs.Push.Targets, historyArgs.Push.Category)
+	default:
+		writeUsage(commandParser)
+		Log.Error("Missing command: list, search, show or push")
+		exit(1)
+	}
+
+}
+
+func findHistoryEntry(entries []HistoryEntry, id int) HistoryEntry {
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	Log.Error("No history entry with id %d", id)
+	exit(1)
+	return HistoryEntry{}
+}
+
+func listHistory(entries []HistoryEntry) {
+	if len(entries) == 0 {
+		Log.Info("No history entries found")
+		return
+	}
+	Log.Info("%-6s %-17s %-15s %-20s %s", "ID", "Added", "Category", "Targets", "Title")
+	for _, entry := range entries {
+		Log.Info("%-6d %-17s %-15s %-20s %s", entry.ID, entry.Added.Local().Format("02.01.2006 15:04"), entry.Category, strings.Join(entry.Targets, ","), entry.Title)
+	}
+}
+
+func showHistoryEntry(entry HistoryEntry) {
+	Log.Info("ID:       %s", blue(entry.ID))
+	Log.Info("Title:    %s", blue(entry.Title))
+	Log.Info("Header:   %s", blue(entry.Header))
+	Log.Info("Password: %s", blue(entry.Password))
+	if len(entry.Groups) > 0 {
+		Log.Info("Groups:   %s", blue(strings.Join(entry.Groups, ", ")))
+	}
+	if entry.PostDate > 0 {
+		Log.Info("Date:     %s", blue(time.Unix(entry.PostDate, 0).Format("02.01.2006 15:04:05 MST")))
+	}
+	Log.Info("Engine:   %s", blue(entry.Engine))
+	Log.Info("Category: %s", blue(entry.Category))
+	Log.Info("Targets:  %s", blue(strings.Join(entry.Targets, ", ")))
+	if entry.Route != "" {
+		Log.Info("Route:    %s", blue(entry.Route))
+	}
+	Log.Info("Added:    %s", blue(entry.Added.Local().Format("02.01.2006 15:04:05 MST")))
+	if _, err := os.Stat(historyNzbPath(entry.ID)); err == nil {
+		Log.Info("NZB file: %s", blue(historyNzbPath(entry.ID)))
+	} else {
+		Log.Info("NZB file: %s", blue("not stored"))
+	}
+}
+
+// function to push the stored nzb file of a history entry again
+func pushHistoryEntry(entry HistoryEntry, targetNames []string, category string) {
+
+	nzbfile, err := os.ReadFile(historyNzbPath(entry.ID))
+	if err != nil {
+		Log.Error("The NZB file of history entry %d is not available: %s", entry.ID, err.Error())
+		exit(1)
+	}
+
+	if len(targetNames) == 0 {
+		targetNames = conf.General.Targets
+	}
+	for _, target := range targetNames {
+		if _, ok := targets[target]; !ok {
+			Log.Error("Undefined target '%s'", target)
+			exit(1)
+		}
+	}
+	if category == "" {
+		category = entry.Category
+	}
+
+	// the targets use the global arguments and the pushed nzb file
+	if nzb, err := nzbparser.ParseString(string(nzbfile)); err == nil {
+		pushedNzb = &Result{SearchEngine: entry.Engine, Nzb: nzb}
+	}
+	args.Header = entry.Header
+	args.Title = entry.Title
+	args.Password = entry.Password
+	args.Groups = entry.Groups
+	args.UnixDate = entry.PostDate
+	args.Category = category
+
+	Log.Info("Pushing history entry %d: %s", entry.ID, blue(entry.Title))
+	options := newJobOptions(category)
+	pushedTargets, _, unreachableTargets, hasError := pushToTargets(targetNames, string(nzbfile), category, options)
+	if hasError {
+		spoolFailedPush(entry.Engine, string(nzbfile), category, options, unreachableTargets)
+	}
+	if len(pushedTargets) > 0 && conf.History.Enable {
+		entry.Category = category
+		entry.Targets = pushedTargets
+		entry.Route = fmt.Sprintf("pushed again from history entry %d", entry.ID)
+		entry.Added = time.Now()
+		entry.ID = 0
+		if id, err := addHistoryEntry(entry); err != nil {
+			Log.Warn("Unable to write history: %s", err.Error())
+		} else if conf.History.StoreNzb {
+			storeHistoryNzb(id, string(nzbfile))
+		}
+	}
+	if hasError {
+		exit(1)
+	}
+
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// job context passed to the hooks as JSON on stdin and as environment variables
+type hookContext struct {
+	Event         string      `json:"event"`
+	Title         string      `json:"title"`
+	Header        string      `json:"header"`
+	Password      string      `json:"password"`
+	Groups        []string    `json:"groups"`
+	Date          int64       `json:"date"`
+	Category      string      `json:"category"`
+	Engine        string      `json:"engine,omitempty"`
+	NzbFile       string      `json:"nzb_file,omitempty"` // path of the nzb file (pre_push only)
+	Result        *hookResult `json:"result,omitempty"`
+	Targets       []string    `json:"targets,omitempty"`
+	FailedTargets []string    `json:"failed_targets,omitempty"`
+}
+
+// completeness of the found nzb file
+type hookResult struct {
+	Subject                string  `json:"subject"`
+	Bytes                  int64   `json:"bytes"`
+	Files                  int     `json:"files"`
+	TotalFiles             int     `json:"total_files"`
+	Segments               int     `json:"segments"`
+	TotalSegments          int     `json:"total_segments"`
+	SegmentsMissingPercent float64 `json:"segments_missing_percent"`
+	Complete               bool    `json:"complete"`
+}
+
+// returns the context of the current job for the hook event
+func newHookContext(event string, result *Result, category string) hookContext {
+	hook := hookContext{
+		Event:    event,
+		Title:    args.Title,
+		Header:   args.Header,
+		Password: args.Password,
+		Groups:   args.Groups,
+		Date:     args.UnixDate,
+		Category: category,
+	}
+	if result != nil {
+		hook.Engine = result.SearchEngine
+		hook.Result = &hookResult{
+			Bytes:                  result.Nzb.Bytes,
+			Files:                  result.Nzb.Files.Len(),
+			TotalFiles:             result.Nzb.TotalFiles,
+			Segments:               result.Nzb.Segments,
+			TotalSegments:          result.Nzb.TotalSegments,
+			SegmentsMissingPercent: result.SegmentsMissingPercent,
+			Complete:               result.FilesComplete && result.SegmentsComplete,
+		}
+		if result.Nzb.Files.Len() > 0 {
+			hook.Result.Subject = result.Nzb.Files[0].Subject
+		}
+	}
+	return hook
+}
+
+// returns the environment variables of the hook context
+func (c hookContext) environment() []string {
+	env := []string{
+		"NZBMONKEY_EVENT=" + c.Event,
+		"NZBMONKEY_TITLE=" + c.Title,
+		"NZBMONKEY_HEADER=" + c.Header,
+		"NZBMONKEY_PASSWORD=" + c.Password,
+		"NZBMONKEY_GROUPS=" + strings.Join(c.Groups, ","),
+		"NZBMONKEY_DATE=" + strconv.FormatInt(c.Date, 10),
+		"NZBMONKEY_CATEGORY=" + c.Category,
+		"NZBMONKEY_ENGINE=" + c.Engine,
+		"NZBMONKEY_NZB_FILE=" + c.NzbFile,
+		"NZBMONKEY_TARGETS=" + strings.Join(c.Targets, ","),
+		"NZBMONKEY_FAILED_TARGETS=" + strings.Join(c.FailedTargets, ","),
+	}
+	if c.Result != nil {
+		env = append(env,
+			"NZBMONKEY_SUBJECT="+c.Result.Subject,
+			"NZBMONKEY_BYTES="+strconv.FormatInt(c.Result.Bytes, 10),
+			"NZBMONKEY_COMPLETE="+strconv.FormatBool(c.Result.Complete),
+		)
+	}
+	return env
+}
+
+// returns the configured command of the hook event
+func hookCommand(event string) string {
+	switch event {
+	case "pre_search":
+		return conf.Hooks.PreSearch
+	case "post_found":
+		return conf.Hooks.PostFound
+	case "pre_push":
+		return conf.Hooks.PrePush
+	case "post_push":
+		return conf.Hooks.PostPush
+	}
+	return ""
+}
+
+// function to run the command of a hook event
+// returns the "key=value" lines written to stdout and false if the command exited with a non-zero exit code
+// errors running the command (e.g. a timeout) are only logged and do not influence the pipeline
+func runHook(hook hookContext) (map[string]string, bool) {
+
+	command := strings.TrimSpace(hookCommand(hook.Event))
+	if command == "" {
+		return nil, true
+	}
+
+	fmt.Println()
+	Log.Info("Running the %s hook ...", hook.Event)
+
+	input, err := json.Marshal(hook)
+	if err != nil {
+		Log.Warn("Unable to run the %s hook: %s", hook.Event, err.Error())
+		return nil, true
+	}
+
+	timeout := time.Duration(max(conf.Hooks.Timeout, 1)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), hook.environment()...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	err = cmd.Run()
+
+	// "key=value" lines are returned, all other lines are shown
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if key, value, ok := strings.Cut(line, "="); ok && key != "" && !strings.ContainsAny(key, " \t") {
+			values[strings.ToLower(key)] = strings.TrimSpace(value)
+		} else if line != "" {
+			Log.Info("   %s", line)
+		}
+	}
+
+	if ctx.Err() != nil {
+		Log.Warn("The %s hook timed out after %s", hook.Event, timeout)
+		return nil, true
+	}
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		Log.Debug("The %s hook exited with exit code %d", hook.Event, exitError.ExitCode())
+		return values, false
+	}
+	if err != nil {
+		Log.Warn("Unable to run the %s hook: %s", hook.Event, err.Error())
+		return nil, true
+	}
+	return values, true
+
+}
+
+// returns the command to run the command line with the shell of the system
+// stderr is passed through
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	// do not wait for child processes still holding stdout after a timeout
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// function to run the pre_search hook
+// the hook can rewrite the title, header, password, groups and category or abort the search
+func preSearchHook() bool {
+	values, ok := runHook(newHookContext("pre_search", nil, args.Category))
+	if !ok {
+		return false
+	}
+	if value, ok := values["title"]; ok {
+		args.Title = value
+	}
+	if value, ok := values["header"]; ok {
+		args.Header = value
+	}
+	if value, ok := values["password"]; ok {
+		args.Password = value
+	}
+	if value, ok := values["groups"]; ok {
+		args.Groups = splitList(value)
+	}
+	if value, ok := values["category"]; ok {
+		args.Category = value
+	}
+	return true
+}
+
+// function to run the post_found hook
+// returns false if the hook vetoed the found nzb file
+func postFoundHook(result *Result) bool {
+	_, ok := runHook(newHookContext("post_found", result, ""))
+	return ok
+}
+
+// function to run the pre_push hook
+// the hook can modify the nzb file on disk and change the category or abort the push
+func prePushHook(result *Result, nzbfile string, category string) (string, string, error) {
+	if strings.TrimSpace(conf.Hooks.PrePush) == "" {
+		return nzbfile, category, nil
+	}
+	file, err := os.CreateTemp(tempPath, "nzb-monkey-go-*.nzb")
+	if err != nil {
+		return nzbfile, category, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(nzbfile)
+	file.Close()
+	if err != nil {
+		return nzbfile, category, err
+	}
+
+	hook := newHookContext("pre_push", result, category)
+	hook.NzbFile = file.Name()
+	values, ok := runHook(hook)
+	if !ok {
+		return nzbfile, category, fmt.Errorf("the push was aborted by the pre_push hook")
+	}
+	if value, ok := values["category"]; ok {
+		category = value
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nzbfile, category, err
+	}
+	return string(data), category, nil
+}
+
+// function to run the post_push hook
+func postPushHook(result *Result, category string, pushedTargets []string, failedTargets []string) {
+	hook := newHookContext("post_push", result, category)
+	hook.Targets = pushedTargets
+	hook.FailedTargets = failedTargets
+	if _, ok := runHook(hook); !ok {
+		Log.Warn("The post_push hook failed")
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/base64"
-	"fmt"
+	"errors"
 	"io"
 	"maps"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -36,6 +40,20 @@ func postURLWithHeaders(rawURL string, body io.Reader, contentType string, heade
 
 // doRequest is the core HTTP helper. It executes the request using the provided client.
 func doRequest(client *http.Client, method string, rawURL string, body io.Reader, contentType string, headers map[string]string) ([]byte, error) {
+	// serve the recorded response instead of using the network
+	if args.Replay != "" {
+		return replayRequest(method, rawURL)
+	}
+
+	// the body is buffered to record it
+	var requestBody []byte
+	if args.TraceHTTP != "" {
+		var err error
+		if requestBody, body, err = bufferBody(body); err != nil {
+			return nil, err
+		}
+	}
+
 	req, err := http.NewRequest(method, rawURL, body)
 	if err != nil {
 		return nil, err
@@ -51,22 +69,72 @@ func doRequest(client *http.Client, method string, rawURL string, body io.Reader
 
 	resp, err := client.Do(req)
 	if err != nil {
+		if args.TraceHTTP != "" {
+			traceRequest(req, requestBody, nil, nil, err)
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := io.ReadAll(resp.Body)
+	if args.TraceHTTP != "" {
+		traceRequest(req, requestBody, resp, responseBody, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("%s", resp.Status)
+		return nil, &httpStatusError{statusCode: resp.StatusCode, status: resp.Status, body: responseBody}
 	}
 
 	return responseBody, nil
 }
 
+// httpStatusError is returned by doRequest if the response status is not 200 OK.
+type httpStatusError struct {
+	statusCode int
+	status     string
+	body       []byte
+}
+
+func (e *httpStatusError) Error() string {
+	return e.status
+}
+
+// isTransientError returns true for timeouts, refused or reset connections and server side errors
+// that are worth retrying. Certificate errors, unknown hosts and invalid urls are permanent.
+func isTransientError(err error) bool {
+	var statusError *httpStatusError
+	if errors.As(err, &statusError) {
+		return statusError.statusCode >= 500 || statusError.statusCode == http.StatusTooManyRequests
+	}
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return dnsError.IsTimeout || dnsError.IsTemporary
+	}
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
+}
+
+// isUnsentError returns true if the request failed before it was sent to the server
+// or was rejected by the server without being processed, so a POST request can be sent again.
+func isUnsentError(err error) bool {
+	var statusError *httpStatusError
+	if errors.As(err, &statusError) {
+		return statusError.statusCode == http.StatusServiceUnavailable || statusError.statusCode == http.StatusTooManyRequests
+	}
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
+}
+
 // request builds and executes an HTTP request for a target, constructing the URL from the
 // provided config struct (accessed via reflection) and applying optional headers, query
 // parameters, body, and content type.
@@ -76,31 +144,38 @@ func request(conf any, httpMethod string, path string, headers map[string]string
 	transportCfg := http.DefaultTransport.(*http.Transport).Clone()
 
 	// generate URL
-	var scheme string
-	if values.FieldByName("Ssl").Bool() {
-		scheme = "https://"
-		if values.FieldByName("SkipCheck").Bool() {
-			transportCfg.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	// the url of a target configured with an url (e.g. a webhook) is used as it is to keep IPv6 addresses and user info
+	var u *url.URL
+	var err error
+	if field := values.FieldByName("URL"); field.IsValid() {
+		if u, err = url.Parse(field.String()); err != nil {
+			return nil, err
+		}
+		if strings.Trim(path, " /") != "" {
+			u = u.JoinPath(strings.Trim(path, " /"))
 		}
 	} else {
-		scheme = "http://"
-	}
-	fullUrl := fmt.Sprintf("%s%s", scheme, values.FieldByName("Host").String())
-	if values.FieldByName("Port").Int() > 0 {
-		fullUrl += fmt.Sprintf(":%d", values.FieldByName("Port").Int())
-	}
-	if strings.Trim(values.FieldByName("Basepath").String(), " /") != "" {
-		fullUrl += fmt.Sprintf("/%s", strings.Trim(values.FieldByName("Basepath").String(), " /"))
+		u = &url.URL{Scheme: "http", Host: strings.Trim(values.FieldByName("Host").String(), "[]")}
+		if values.FieldByName("Ssl").Bool() {
+			u.Scheme = "https"
+		}
+		if port := values.FieldByName("Port").Int(); port > 0 {
+			u.Host = net.JoinHostPort(u.Host, strconv.FormatInt(port, 10))
+		} else if strings.Contains(u.Host, ":") {
+			u.Host = "[" + u.Host + "]"
+		}
+		if basepath := strings.Trim(values.FieldByName("Basepath").String(), " /"); basepath != "" {
+			u.Path += "/" + basepath
+		}
+		if path != "" {
+			u.Path += "/" + strings.Trim(path, " /")
+		}
 	}
-	if path != "" {
-		fullUrl += fmt.Sprintf("/%s", strings.Trim(path, " /"))
+	if u.Scheme == "https" && values.FieldByName("SkipCheck").Bool() {
+		transportCfg.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
 	// append the query parameters
-	u, err := url.Parse(fullUrl)
-	if err != nil {
-		return nil, err
-	}
 	q := u.Query()
 	for k, v := range queryParameters {
 		q.Set(k, strings.Join(v, ","))
@@ -122,5 +197,43 @@ func request(conf any, httpMethod string, path string, headers map[string]string
 	} else {
 		client.Timeout = defaultTimeout
 	}
-	return doRequest(client, httpMethod, u.String(), body, contentType, allHeaders)
+
+	// retry transient errors with an exponential backoff if configured for the target
+	var retries int64
+	retryDelay := time.Duration(2) * time.Second
+	if field := values.FieldByName("Retries"); field.IsValid() {
+		retries = field.Int()
+	}
+	if field := values.FieldByName("RetryDelay"); field.IsValid() && field.Int() > 0 {
+		retryDelay = time.Duration(field.Int()) * time.Second
+	}
+	// a sent POST or PATCH request may already have been processed (e.g. the job was added) and is only retried if configured
+	retrySent := httpMethod != http.MethodPost && httpMethod != http.MethodPatch
+	if field := values.FieldByName("RetryPost"); field.IsValid() && field.Bool() {
+		retrySent = true
+	}
+	if retries <= 0 {
+		return doRequest(client, httpMethod, u.String(), body, contentType, allHeaders)
+	}
+
+	// the body must be buffered to be sent again
+	var bodyBytes []byte
+	if body != nil {
+		if bodyBytes, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := int64(0); ; attempt++ {
+		var attemptBody io.Reader
+		if body != nil {
+			attemptBody = bytes.NewReader(bodyBytes)
+		}
+		response, err := doRequest(client, httpMethod, u.String(), attemptBody, contentType, allHeaders)
+		if err == nil || attempt >= retries || !isTransientError(err) || (!retrySent && !isUnsentError(err)) {
+			return response, err
+		}
+		Log.Warn("Request failed: %s. Retrying in %s (%d/%d) ...", err.Error(), retryDelay, attempt+1, retries)
+		time.Sleep(retryDelay)
+		retryDelay *= 2
+	}
 }
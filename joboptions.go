@@ -0,0 +1,75 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// job options structure for the download clients
+// empty values are not set and the default of the download client is used
+type JobOptions struct {
+	Priority       string `ini:"priority" json:"priority,omitempty"`
+	PostProcessing string `ini:"pp" json:"pp,omitempty"`
+	Script         string `ini:"script" json:"script,omitempty"`
+	DupeKey        string `ini:"dupe_key" json:"dupe_key,omitempty"`
+	DupeScore      string `ini:"dupe_score" json:"dupe_score,omitempty"`
+	DupeMode       string `ini:"dupe_mode" json:"dupe_mode,omitempty"`
+	PPParameters   string `ini:"pp_parameters" json:"pp_parameters,omitempty"`
+}
+
+// job options for the current push (options of the category and the arguments)
+// the options of the target are overwritten by these options
+var jobOptions JobOptions
+
+// returns the options with the set values of override taking precedence
+func (o JobOptions) merge(override JobOptions) JobOptions {
+	return JobOptions{
+		Priority:       cmp.Or(override.Priority, o.Priority),
+		PostProcessing: cmp.Or(override.PostProcessing, o.PostProcessing),
+		Script:         cmp.Or(override.Script, o.Script),
+		DupeKey:        cmp.Or(override.DupeKey, o.DupeKey),
+		DupeScore:      cmp.Or(override.DupeScore, o.DupeScore),
+		DupeMode:       cmp.Or(override.DupeMode, o.DupeMode),
+		PPParameters:   strings.Trim(o.PPParameters+","+override.PPParameters, ","),
+	}
+}
+
+// returns the job options for the push from the options of the category and the arguments
+func newJobOptions(category string) JobOptions {
+	return conf.CategoryJobOptions[strings.ToLower(category)].merge(JobOptions{
+		Priority:       args.Priority,
+		PostProcessing: args.PostProcessing,
+		Script:         args.Script,
+		DupeKey:        args.DupeKey,
+		DupeScore:      args.DupeScore,
+		DupeMode:       args.DupeMode,
+		PPParameters:   strings.Join(args.PPParameters, ","),
+	})
+}
+
+// returns the priority as number for the given priority names
+// numbers are returned unchanged
+func parsePriority(priority string, priorities map[string]int) (int, error) {
+	if value, ok := priorities[strings.ToLower(priority)]; ok {
+		return value, nil
+	}
+	if value, err := strconv.Atoi(priority); err == nil {
+		return value, nil
+	}
+	return 0, fmt.Errorf("unknown priority '%s'", priority)
+}
+
+// parses the post processing parameters in the format "name=value, name=value"
+func parsePPParameters(parameters string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, parameter := range splitList(parameters) {
+		name, value, ok := strings.Cut(parameter, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid post processing parameter '%s'", parameter)
+		}
+		result[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
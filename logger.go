@@ -1,125 +1,307 @@
 package main
 
 import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/acarl005/stripansi"
 	"github.com/fatih/color"
 )
 
-type Logger struct {
-	Error func(string, ...interface{})
-	Warn  func(string, ...interface{})
-	Info  func(string, ...interface{})
-	Succ  func(string, ...interface{})
-	Debug func(string, ...interface{})
-}
-
-// global error logger variables
-var (
-	logFile *os.File
-	logger  *log.Logger
-	Log     = Logger{
-		Error: logError,
-		Warn:  logWarn,
-		Info:  logInfo,
-		Succ:  logSuccess,
-		Debug: logDebug,
-	}
+// log levels
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+	levelOff
 )
 
-// error logger function
-func logError(logText string, vars ...interface{}) {
-	logEntry("error", logText, vars...)
+var logLevels = map[string]int{
+	"debug":   levelDebug,
+	"info":    levelInfo,
+	"warn":    levelWarn,
+	"warning": levelWarn,
+	"error":   levelError,
+	"off":     levelOff,
 }
 
-func logWarn(logText string, vars ...interface{}) {
-	logEntry("warn", logText, vars...)
+// leveled logger
+// the messages are always shown on the console (except debug messages)
+// and written to the log file if their level is at least the configured level
+type Logger struct {
+	mutex      sync.Mutex
+	configured bool
+	level      int
+	path       string
+	json       bool
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	file       *os.File
+	size       int64
+	started    time.Time
+	runID      string    // correlation id to tell the lines of concurrent instances apart
+	pending    []logLine // lines logged before the logger was configured
 }
 
-func logInfo(logText string, vars ...interface{}) {
-	logEntry("info", logText, vars...)
+// line of the log file
+type logLine struct {
+	time  time.Time
+	level int
+	label string
+	text  string
 }
 
-func logSuccess(logText string, vars ...interface{}) {
-	logEntry("success", logText, vars...)
+// global logger
+var Log = &Logger{level: levelOff, runID: newRunID()}
+
+// returns a random id for the run
+func newRunID() string {
+	id := make([]byte, 4)
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
 
-func logDebug(logText string, vars ...interface{}) {
-	logEntry("debug", logText, vars...)
+func (l *Logger) Error(logText string, vars ...interface{}) {
+	l.entry(levelError, "ERROR", color.FgRed, logText, vars...)
 }
 
-func logEntry(logType string, logText string, vars ...interface{}) {
+func (l *Logger) Warn(logText string, vars ...interface{}) {
+	l.entry(levelWarn, "WARNING", color.FgYellow, logText, vars...)
+}
 
-	// init logger if nil
-	if args.Debug && logFile == nil {
-		initLogger(logFilePath)
-	}
+func (l *Logger) Info(logText string, vars ...interface{}) {
+	l.entry(levelInfo, "INFO", 0, logText, vars...)
+}
 
-	// log error
-	if logType == "error" {
-		if args.Debug {
-			logger.Printf("ERROR: %s\n", stripansi.Strip(strings.Trim(fmt.Sprintf(logText, vars...), "\n")))
-		}
-		color.Set(color.FgRed)
-		fmt.Printf("   ERROR:    %s\n", fmt.Sprintf(logText, vars...))
+func (l *Logger) Succ(logText string, vars ...interface{}) {
+	l.entry(levelInfo, "SUCCESS", color.FgGreen, logText, vars...)
+}
+
+func (l *Logger) Debug(logText string, vars ...interface{}) {
+	l.entry(levelDebug, "DEBUG", 0, logText, vars...)
+}
+
+func (l *Logger) entry(level int, label string, textColor color.Attribute, logText string, vars ...interface{}) {
+
+	// the secrets are redacted once for the console and the log file
+	text := redact(fmt.Sprintf(logText, vars...))
+
+	// show on console
+	switch {
+	case level == levelDebug:
+	case textColor != 0:
+		color.Set(textColor)
+		fmt.Printf("   %-9s %s\n", label+":", text)
 		color.Unset()
+	default:
+		fmt.Printf("   %s\n", text)
 	}
 
-	// log warn
-	if logType == "warn" {
-		if args.Debug {
-			logger.Printf("WARNING: %s\n", stripansi.Strip(strings.Trim(fmt.Sprintf(logText, vars...), "\n")))
-		}
-		color.Set(color.FgYellow)
-		fmt.Printf("   WARNING:  %s\n", fmt.Sprintf(logText, vars...))
-		color.Unset()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	line := logLine{time: time.Now(), level: level, label: label, text: stripansi.Strip(strings.Trim(text, "\n"))}
+	// the level and the log file are only known after the arguments and the configuration were loaded
+	if !l.configured {
+		l.pending = append(l.pending, line)
+		return
+	}
+	if level < l.level {
+		return
 	}
+	l.write(line)
 
-	// log info
-	if logType == "info" {
-		if args.Debug {
-			logger.Printf("INFO: %s\n", stripansi.Strip(strings.Trim(fmt.Sprintf(logText, vars...), "\n")))
-		}
-		fmt.Printf("   %s\n", fmt.Sprintf(logText, vars...))
+}
+
+// writes the line to the log file
+// must be called with the mutex locked
+func (l *Logger) write(entry logLine) {
+
+	if l.file == nil && !l.open(entry.time) {
+		return
 	}
 
-	// log success
-	if logType == "success" {
-		if args.Debug {
-			logger.Printf("SUCCESS: %s\n", stripansi.Strip(strings.Trim(fmt.Sprintf(logText, vars...), "\n")))
+	if (l.maxSize > 0 && l.size >= l.maxSize) || (l.maxAge > 0 && entry.time.Sub(l.started) >= l.maxAge) {
+		l.rotate(entry.time)
+		if l.file == nil {
+			return
 		}
-		color.Set(color.FgGreen)
-		fmt.Printf("   SUCCESS:  %s\n", fmt.Sprintf(logText, vars...))
-		color.Unset()
 	}
 
-	// log debug
-	if logType == "debug" && args.Debug {
-		logger.Printf("DEBUG: %s\n", stripansi.Strip(strings.Trim(fmt.Sprintf(logText, vars...), "\n")))
+	text := entry.text
+	var line string
+	if l.json {
+		data, _ := json.Marshal(struct {
+			Time    string `json:"time"`
+			Level   string `json:"level"`
+			Run     string `json:"run"`
+			Message string `json:"msg"`
+		}{entry.time.Format(time.RFC3339), strings.ToLower(entry.label), l.runID, text})
+		line = string(data) + "\n"
+	} else {
+		line = fmt.Sprintf("%s [%s] %s: %s\n", entry.time.Format("2006/01/02 15:04:05"), l.runID, entry.label, text)
+	}
+	if n, err := l.file.WriteString(line); err == nil {
+		l.size += int64(n)
 	}
 
 }
 
-func initLogger(file string) {
-	var err error
-	if logFile, err = os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666); err == nil {
-		logger = log.New(logFile, "", log.Ldate|log.Ltime)
-		logger.Printf("INFO: %s %s started", appName, appVersion)
+// function to configure the logger from the arguments and the configuration
+// the level of the arguments has precedence and --debug or debug = true is the level debug
+func (l *Logger) configure() {
+
+	cfg := conf.Log
+	level := cfg.Level
+	if conf.General.Debug && level == "" {
+		level = "debug"
+	}
+	if args.Debug {
+		level = "debug"
+	}
+	if args.LogLevel != "" {
+		level = args.LogLevel
+	}
+	if level == "" {
+		level = "off"
+	}
+
+	l.configured = true
+	if value, ok := logLevels[strings.ToLower(level)]; ok {
+		l.level = value
 	} else {
+		l.level = levelOff
+		color.Set(color.FgYellow)
+		fmt.Printf("   WARNING:  Unknown log level '%s'\n", level)
+		color.Unset()
+	}
+	path := logFilePath
+	if cfg.File != "" {
+		path = cfg.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(homePath, path)
+		}
+	}
+	if l.file != nil && path != l.path {
+		l.file.Close()
+		l.file = nil
+	}
+	l.path = path
+	l.json = strings.ToLower(cfg.Format) == "json"
+	l.maxSize = int64(cfg.MaxSize) * 1024 * 1024
+	l.maxAge = time.Duration(cfg.MaxAge) * 24 * time.Hour
+	l.maxBackups = cfg.MaxBackups
+
+}
+
+// function to configure the logger again after the configuration was loaded
+// the lines logged before are written to the log file with the configured level
+func (l *Logger) reconfigure() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.configure()
+	l.flush()
+}
+
+// writes the lines logged before the logger was configured
+// must be called with the mutex locked
+func (l *Logger) flush() {
+	for _, line := range l.pending {
+		if line.level >= l.level {
+			l.write(line)
+		}
+	}
+	l.pending = nil
+}
+
+// opens the log file and returns false if the file cannot be opened
+// must be called with the mutex locked
+func (l *Logger) open(now time.Time) bool {
+	var err error
+	if l.file, err = os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666); err != nil {
+		l.file = nil
+		l.level = levelOff
 		color.Set(color.FgRed)
-		fmt.Printf("   ERROR: Fatal error while opening log file '%s': %s\n", file, err.Error())
+		fmt.Printf("   ERROR:    Unable to open log file '%s': %s\n", l.path, err.Error())
 		color.Unset()
-		exit(1)
+		return false
 	}
+	l.size, l.started = 0, now
+	if info, err := l.file.Stat(); err == nil {
+		l.size = info.Size()
+		if started, ok := logFileStarted(l.path); ok {
+			l.started = started
+		}
+	}
+	if l.size == 0 {
+		l.write(logLine{time: now, level: levelInfo, label: "INFO", text: fmt.Sprintf("%s %s started", appName, appVersion)})
+	}
+	return true
+}
+
+// renames the log file to <file>.1 and the older backups to <file>.2 ...
+// backups exceeding the maximum number of backups are deleted
+// must be called with the mutex locked
+func (l *Logger) rotate(now time.Time) {
+	l.file.Close()
+	l.file = nil
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if l.maxBackups > 0 {
+		os.Rename(l.path, l.path+".1")
+	} else {
+		os.Remove(l.path)
+	}
+	l.open(now)
+}
+
+// returns the time of the first line of the log file
+func logFileStarted(path string) (time.Time, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil {
+		return time.Time{}, false
+	}
+	var entry struct {
+		Time time.Time `json:"time"`
+	}
+	if json.Unmarshal([]byte(line), &entry) == nil && !entry.Time.IsZero() {
+		return entry.Time, true
+	}
+	if len(line) >= 19 {
+		if started, err := time.ParseInLocation("2006/01/02 15:04:05", line[:19], time.Local); err == nil {
+			return started, true
+		}
+	}
+	return time.Time{}, false
 }
 
 func logClose() {
 	// clean up
-	if logFile != nil {
-		logFile.Close()
+	Log.mutex.Lock()
+	defer Log.mutex.Unlock()
+	// the configuration was not loaded: the lines are written with the settings of the arguments
+	if !Log.configured {
+		Log.configure()
+		Log.flush()
+	}
+	if Log.file != nil {
+		Log.file.Close()
+		Log.file = nil
 	}
 }
@@ -25,6 +25,15 @@ type Result struct {
 	SegmentsMissing        int
 	SegmentsMissingPercent float64
 	SegmentsComplete       bool
+	Verified               bool
+	SegmentsUnavailable    int
+	FilesUnavailable       []int // estimated unavailable segments per file
+	Par2Checked            bool
+	Repairable             bool
+	RecoveryBlocks         int
+	DamagedBlocks          int
+	Par2FilesMissing       int        // par2 files missing in the NZB file
+	Par2Index              *par2Index // par2 index if it was read from the news server
 }
 
 // global variables
@@ -36,6 +45,8 @@ var (
 	homePath                  string
 	tempPath                  string
 	results                   = make([]Result, 0)
+	candidates                = make([]Result, 0)
+	pushedNzb                 *Result // the NZB file that is pushed to the targets
 	filesColor, segmentsColor func(a ...interface{}) string
 	red                       = color.New(color.FgRed).SprintFunc()
 	yellow                    = color.New(color.FgYellow).SprintFunc()
@@ -61,7 +72,7 @@ func init() {
 	// change working directory
 	// important for url protocol handling (otherwise work dir will be system32 on windows)
 	if err := os.Chdir(appPath); err != nil {
-		Log.Error("Cannot change working directory: ", err)
+		Log.Error("Cannot change working directory: %s", err.Error())
 		os.Exit(1)
 	}
 
@@ -88,8 +99,24 @@ func main() {
 	parseArguments()
 	setConfPath()
 	checkForConfig()
+	if command != nil {
+		loadConfig()
+		initHTTPTrace()
+		command.run()
+		exit(0)
+	}
 	checkArguments()
 	loadConfig()
+	initHTTPTrace()
+	if conf.Spool.Enable && conf.Spool.AutoFlush {
+		autoFlushSpool()
+	}
+
+	if !preSearchHook() {
+		fmt.Println()
+		Log.Error("The search was aborted by the pre_search hook")
+		exit(1)
+	}
 
 	fmt.Println()
 	Log.Info("Arguments provided:")
@@ -118,30 +145,33 @@ func main() {
 	if args.Category != "" {
 		Log.Info("Category: %s", blue(args.Category))
 	}
+	if len(args.Flags) > 0 {
+		Log.Info("Flags:    %s", blue(strings.Join(args.Flags, ", ")))
+	}
 
 	for _, name := range conf.Searchengines {
 		fmt.Println()
 		Log.Info("Searching on %s ...", searchEngines[name].name)
 		if err := searchEngines[name].search(searchEngines[name], searchEngines[name].name); err != nil {
-			Log.Warn(err.Error())
+			Log.Warn("%s", err.Error())
 		}
 	}
 
-	if len(results) > 0 && conf.Nzbcheck.BestNZB {
+	if conf.Nzbcheck.Merge {
+		mergeResults()
+	}
+
+	if len(results) > 0 && (conf.Nzbcheck.BestNZB || conf.Nzbcheck.Merge) {
 		fmt.Println()
 		Log.Info("Using best NZB file found")
 		sort.SliceStable(results, func(i, j int) bool {
-			// Sort first by files missing
-			if results[i].FilesMissing != results[j].FilesMissing {
-				return results[i].FilesMissing < results[j].FilesMissing
-			}
-			// ... and then by segments missing
-			return results[i].SegmentsMissingPercent < results[j].SegmentsMissingPercent
+			return isBetterResult(results[i], results[j])
 		})
 		processFoundNzb(&results[0])
 	} else {
 		fmt.Println()
 		Log.Error("No results found for header '%s'", args.Header)
+		notify(eventNotFound, nil, args.Category, nil, fmt.Sprintf("No results found for header '%s'", args.Header))
 		exit(1)
 	}
 }
@@ -157,16 +187,93 @@ func prettyByteSize(b int) string {
 	return fmt.Sprintf("%.1fYiB", bf)
 }
 
-func processResult(nzb *nzbparser.Nzb, name string) {
+// returns true if result a is more complete than result b
+func isBetterResult(a Result, b Result) bool {
+	// prefer repairable results
+	if a.Par2Checked && b.Par2Checked && a.Repairable != b.Repairable {
+		return a.Repairable
+	}
+	// compare then by files missing
+	if a.FilesMissing != b.FilesMissing {
+		return a.FilesMissing < b.FilesMissing
+	}
+	// ... and then by segments missing
+	return a.SegmentsMissingPercent < b.SegmentsMissingPercent
+}
+
+func newResult(nzb *nzbparser.Nzb, name string) Result {
 	result := Result{
-		SearchEngine:           name,
-		Nzb:                    nzb,
-		FilesMissing:           nzb.TotalFiles - nzb.Files.Len(),
-		FilesComplete:          nzb.TotalFiles-nzb.Files.Len() <= conf.Nzbcheck.MaxMissingFiles,
-		SegmentsMissing:        nzb.TotalSegments - nzb.Segments,
-		SegmentsMissingPercent: float64(float64(nzb.TotalSegments-nzb.Segments) / float64(nzb.TotalSegments) * 100),
-		SegmentsComplete:       float64(float64(nzb.TotalSegments-nzb.Segments)/float64(nzb.TotalSegments)*100) <= conf.Nzbcheck.MaxMissingSegmentsPercent,
+		SearchEngine:    name,
+		Nzb:             nzb,
+		FilesMissing:    nzb.TotalFiles - nzb.Files.Len(),
+		SegmentsMissing: nzb.TotalSegments - nzb.Segments,
+	}
+	evaluateResult(&result)
+	return result
+}
+
+// sets the completeness of the result based on the missing files and segments
+func evaluateResult(result *Result) {
+	result.FilesComplete = result.FilesMissing <= conf.Nzbcheck.MaxMissingFiles
+	result.SegmentsMissingPercent = float64(float64(result.SegmentsMissing) / float64(result.Nzb.TotalSegments) * 100)
+	result.SegmentsComplete = result.SegmentsMissingPercent <= conf.Nzbcheck.MaxMissingSegmentsPercent
+
+	// use the par2 evaluation instead of the thresholds if par2 volumes are available
+	if conf.Nzbcheck.Par2Check {
+		evaluatePar2(result)
+		if result.Par2Checked {
+			result.FilesComplete = result.Repairable
+			result.SegmentsComplete = result.Repairable
+		}
+	}
+}
+
+func processResult(nzb *nzbparser.Nzb, name string) {
+	result, ok := checkResult(nzb, name)
+	if !ok {
+		return
+	}
+
+	// keep all candidates for the merge step
+	if conf.Nzbcheck.Merge {
+		candidates = append(candidates, result)
+	}
+
+	addResult(result)
+}
+
+// evaluates and verifies the nzb file and runs the post_found hook
+// returns false if the nzb file was vetoed by the hook
+func checkResult(nzb *nzbparser.Nzb, name string) (Result, bool) {
+	result := newResult(nzb, name)
+	logResult(result)
+
+	// check the availability of the articles on the news server
+	if conf.Nzbcheck.VerifyAvailability {
+		verifyResult(&result)
+	}
+
+	if !postFoundHook(&result) {
+		Log.Warn("NZB file is skipped because it was vetoed by the post_found hook")
+		return result, false
 	}
+	return result, true
+}
+
+// uses the result directly or keeps it to choose the best result
+func addResult(result Result) {
+	if !conf.Nzbcheck.SkipFailed || (result.FilesComplete && result.SegmentsComplete) {
+		if (!conf.Nzbcheck.BestNZB && !conf.Nzbcheck.Merge) || (result.FilesMissing == 0 && result.SegmentsMissing == 0) {
+			processFoundNzb(&result)
+		} else {
+			results = append(results, result)
+		}
+	} else {
+		Log.Warn("NZB file is skipped because it is incomplete!")
+	}
+}
+
+func logResult(result Result) {
 	if result.FilesComplete {
 		filesColor = green
 	} else {
@@ -180,24 +287,21 @@ func processResult(nzb *nzbparser.Nzb, name string) {
 	Log.Info("Found:    %s", green(fmt.Sprintf("%s (%s)", result.Nzb.Files[0].Subject, humanize.Bytes(uint64(result.Nzb.Bytes)))))
 	Log.Info("Files:    %s", filesColor(fmt.Sprintf("%d/%d (Missing files: %d)", result.Nzb.Files.Len(), result.Nzb.TotalFiles, result.FilesMissing)))
 	Log.Info("Segments: %s", segmentsColor(fmt.Sprintf("%d/%d (Missing segments: %f %%)", result.Nzb.Segments, result.Nzb.TotalSegments, result.SegmentsMissingPercent)))
-
-	if !conf.Nzbcheck.SkipFailed || (result.FilesComplete && result.SegmentsComplete) {
-		if !conf.Nzbcheck.BestNZB || (result.FilesMissing == 0 && result.SegmentsMissing == 0) {
-			processFoundNzb(&result)
-		} else {
-			results = append(results, result)
-		}
-	} else {
-		Log.Warn("NZB file is skipped because it is incomplete!")
-	}
+	logPar2(result)
 }
 
 func processFoundNzb(nzb *Result) {
+	pushedNzb = nzb
 	Log.Info("Using NZB file from %s", nzb.SearchEngine)
 	if !nzb.FilesComplete || !nzb.SegmentsComplete {
 		Log.Warn("NZB file is probably incomplete!")
+		notify(eventIncomplete, nzb, args.Category, nil, fmt.Sprintf("The NZB file for '%s' found on %s is probably incomplete (%.2f %% of the segments missing)", args.Title, nzb.SearchEngine, nzb.SegmentsMissingPercent))
+	}
+	if conf.Nzbcheck.Deobfuscate {
+		deobfuscateNzb(nzb)
 	}
-	var category = checkCategories()
+	var category = checkCategories(nzb)
+	filterFiles(nzb, category)
 	nzb.Nzb.Comment = fmt.Sprintf("Downloaded from %s with %s %s", nzb.SearchEngine, appName, appVersion)
 	if nzb.Nzb.Meta == nil {
 		nzb.Nzb.Meta = make(map[string]string)
@@ -206,18 +310,52 @@ func processFoundNzb(nzb *Result) {
 	if args.Password != "" {
 		nzb.Nzb.Meta["password"] = html.EscapeString(args.Password)
 	}
+	if !checkHistory(nzb.Nzb) {
+		exit(0)
+	}
 	var err error
 	var nzbfile string
 	var hasError bool
+	var pushedTargets []string
 	if nzbfile, err = nzbparser.WriteString(nzb.Nzb); err == nil {
-		for _, target := range conf.General.Targets {
-			if err = targets[target].push(nzbfile, category); err != nil {
-				Log.Error(err.Error())
-				hasError = true
+		var modified string
+		if modified, category, err = prePushHook(nzb, nzbfile, category); err == nil && modified != nzbfile {
+			// the nzb file was modified by the pre_push hook
+			nzbfile = modified
+			nzb.Nzb, err = nzbparser.ParseString(nzbfile)
+		}
+	}
+	if err == nil {
+		var failedTargets, unreachableTargets []string
+		pushTargets, route := routeTargets(nzb, category)
+		// targets chosen by the routing with the selected category have not been prompted yet
+		// they are always prompted as each target gets its own category (see manualCategories)
+		// and the selected category is only used if no category was selected before
+		if conf.General.Categorize == "manual" && args.Category == "" {
+			selected := selectCategories(pushTargets)
+			if category == "" {
+				category = selected
 			}
 		}
+		options := newJobOptions(category)
+		pushedTargets, failedTargets, unreachableTargets, hasError = pushToTargets(pushTargets, nzbfile, category, options)
+		if len(pushedTargets) > 0 {
+			addToHistory(nzb, nzbfile, category, pushedTargets, route)
+		}
+		if len(pushedTargets) > 0 {
+			notify(eventFound, nzb, category, pushedTargets, fmt.Sprintf("'%s' was pushed to %s", args.Title, targetNames(pushedTargets)))
+		}
+		if hasError {
+			spoolFailedPush(nzb.SearchEngine, nzbfile, category, options, unreachableTargets)
+			notify(eventPushFailed, nzb, category, failedTargets, fmt.Sprintf("'%s' could not be pushed to %s", args.Title, targetNames(failedTargets)))
+		}
+		postPushHook(nzb, category, pushedTargets, failedTargets)
+		if args.Follow && len(pushedTargets) > 0 {
+			hasError = followTargets(pushedTargets) || hasError
+		}
 	} else {
-		Log.Error(err.Error())
+		Log.Error("%s", err.Error())
+		notify(eventPushFailed, nzb, category, nil, fmt.Sprintf("'%s' could not be pushed: %s", args.Title, err.Error()))
 		hasError = true
 	}
 	if hasError {
@@ -244,6 +382,10 @@ func exit(exitCode int) {
 
 	logClose() // clean up
 
+	if noWait {
+		os.Exit(exitCode)
+	}
+
 	// pause before ending the program
 	fmt.Println()
 	for i := wait_time; i >= 0; i-- {
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Tensai75/nzbparser"
+)
+
+// function to merge the NZB files of the same post found by different search engines
+// the merged NZB file is added to the results if it is more complete than every single candidate
+func mergeResults() {
+
+	for _, group := range groupCandidates(candidates) {
+		if len(group) < 2 {
+			continue
+		}
+
+		// collect the names of the search engines
+		var names []string
+		for _, candidate := range group {
+			if !slices.Contains(names, candidate.SearchEngine) {
+				names = append(names, candidate.SearchEngine)
+			}
+		}
+		if len(names) < 2 {
+			continue
+		}
+
+		fmt.Println()
+		Log.Info("Merging %d NZB files from %s ...", len(group), strings.Join(names, ", "))
+
+		merged, ok := checkResult(mergeNzbs(group), fmt.Sprintf("merged NZB file (%s)", strings.Join(names, ", ")))
+		if !ok {
+			continue
+		}
+
+		better := true
+		for _, candidate := range group {
+			if !isBetterResult(merged, candidate) {
+				Log.Info("The merged NZB file is not more complete than the NZB file from %s", candidate.SearchEngine)
+				better = false
+				break
+			}
+		}
+		if better {
+			addResult(merged)
+		}
+	}
+
+}
+
+// groups the candidates by post
+func groupCandidates(candidates []Result) [][]Result {
+	var groups [][]Result
+	for _, candidate := range candidates {
+		found := false
+		for i, group := range groups {
+			if isSamePost(group[0].Nzb, candidate.Nzb) {
+				groups[i] = append(groups[i], candidate)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []Result{candidate})
+		}
+	}
+	return groups
+}
+
+// part counter at the end of a subject, e.g. "yEnc (1/50)"
+var subjectPartRegexp = regexp.MustCompile(`[(\[]\d+/(\d+)[)\]]\s*$`)
+
+// returns the subject without the number of the part which differs between the search engines
+func normalizeSubject(subject string) string {
+	return subjectPartRegexp.ReplaceAllString(strings.TrimSpace(subject), "(${1})")
+}
+
+// two NZB files refer to the same post if they have the same posters, the same total
+// number of files and the same file subjects
+func isSamePost(a *nzbparser.Nzb, b *nzbparser.Nzb) bool {
+	if a.TotalFiles != b.TotalFiles {
+		return false
+	}
+	postersA := make(map[string]bool)
+	subjectsA := make(map[string]bool)
+	for _, file := range a.Files {
+		postersA[file.Poster] = true
+		subjectsA[normalizeSubject(file.Subject)] = true
+	}
+	postersB := make(map[string]bool)
+	subjectsB := make(map[string]bool)
+	for _, file := range b.Files {
+		postersB[file.Poster] = true
+		subjectsB[normalizeSubject(file.Subject)] = true
+	}
+	return maps.Equal(subjectsA, subjectsB) && maps.Equal(postersA, postersB)
+}
+
+// merges the files and segments of several NZB files into a new NZB file
+func mergeNzbs(group []Result) *nzbparser.Nzb {
+	merged := &nzbparser.Nzb{
+		Meta: make(map[string]string),
+	}
+	// the same file may be listed with a different part counter in the subject by each search engine
+	// so the files get the subject of the first candidate to be combined
+	subjects := make(map[string]string)
+	for _, candidate := range group {
+		maps.Copy(merged.Meta, candidate.Nzb.Meta)
+		for _, file := range candidate.Nzb.Files {
+			if subject, ok := subjects[normalizeSubject(file.Subject)]; ok {
+				file.Subject = subject
+			} else {
+				subjects[normalizeSubject(file.Subject)] = file.Subject
+			}
+			// copy the segments so the candidates stay untouched
+			file.Segments = slices.Clone(file.Segments)
+			file.Groups = slices.Clone(file.Groups)
+			merged.Files = append(merged.Files, file)
+		}
+	}
+	nzbparser.MakeUnique(merged)
+	nzbparser.ScanNzbFile(merged)
+	sort.Sort(merged.Files)
+	for id := range merged.Files {
+		sort.Sort(merged.Files[id].Segments)
+	}
+	return merged
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Tensai75/nzbparser"
+)
+
+// returns a parsed nzb file with one file of 3 segments with the subject and the available segments
+func testMergeNzb(subject string, segments ...int) *nzbparser.Nzb {
+	file := nzbparser.NzbFile{Subject: subject, Poster: "poster@test"}
+	for _, segment := range segments {
+		file.Segments = append(file.Segments, nzbparser.NzbSegment{Number: segment, Bytes: 1000, Id: fmt.Sprintf("%d@test", segment)})
+	}
+	nzb := &nzbparser.Nzb{Files: nzbparser.NzbFiles{file}}
+	nzbparser.ScanNzbFile(nzb)
+	return nzb
+}
+
+func TestMergeNzbs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		a, b     *nzbparser.Nzb
+		files    int
+		segments int
+	}{
+		{"same subject", testMergeNzb(`[1/1] - "file.bin" yEnc (1/3)`, 1, 3), testMergeNzb(`[1/1] - "file.bin" yEnc (1/3)`, 2, 3), 1, 3},
+		{"different part counter", testMergeNzb(`[1/1] - "file.bin" yEnc (1/3)`, 1, 3), testMergeNzb(`[1/1] - "file.bin" yEnc (2/3)`, 2, 3), 1, 3},
+		{"different files", testMergeNzb(`[1/1] - "file.bin" yEnc (1/3)`, 1, 3), testMergeNzb(`[1/1] - "other.bin" yEnc (1/3)`, 2, 3), 2, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := mergeNzbs([]Result{{Nzb: test.a}, {Nzb: test.b}})
+			if merged.Files.Len() != test.files || merged.Segments != test.segments {
+				t.Errorf("got %d files with %d segments, want %d files with %d segments", merged.Files.Len(), merged.Segments, test.files, test.segments)
+			}
+			if merged.Files[0].Subject != test.a.Files[0].Subject {
+				t.Errorf("got subject %q, want %q", merged.Files[0].Subject, test.a.Files[0].Subject)
+			}
+			if test.a.Files[0].Segments.Len() != 2 || test.b.Files[0].Segments.Len() != 2 {
+				t.Error("the candidates were modified")
+			}
+		})
+	}
+
+	if !isSamePost(testMergeNzb(`[1/1] - "file.bin" yEnc (1/3)`, 1), testMergeNzb(`[1/1] - "file.bin" yEnc (2/3)`, 2)) {
+		t.Error("subjects with a different part counter are not the same post")
+	}
+
+}
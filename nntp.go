@@ -1,19 +1,123 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Tensai75/nntp"
 	"github.com/Tensai75/nntpPool"
 )
 
+// returned by fetchArticle if the article does not exist on the news server
+var errArticleNotFound = errors.New("article not found")
+
 var (
-	pool    nntpPool.ConnectionPool
-	maxConn uint32
+	pool           nntpPool.ConnectionPool
+	poolMutex      sync.Mutex
+	maxConn        uint32
+	poolLoggerOnce sync.Once
 )
 
-func initNntpPool() error {
-	var err error
+// initializes the nntp pool if it is not already initialized
+// returns true if the pool was initialized by this call
+// only the caller which initialized the pool closes it, a borrowed pool is still used by its owner
+func initNntpPool() (bool, error) {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+
+	if pool != nil {
+		return false, nil
+	}
+
+	poolLoggerOnce.Do(startNntpPoolLogger)
+
+	newPool, err := nntpPool.New(&nntpPool.Config{
+		Name:                  "",
+		Host:                  conf.Directsearch.Host,
+		Port:                  uint32(conf.Directsearch.Port),
+		SSL:                   conf.Directsearch.SSL,
+		SkipSSLCheck:          true,
+		User:                  conf.Directsearch.Username,
+		Pass:                  conf.Directsearch.Password,
+		ConnWaitTime:          time.Duration(10) * time.Second,
+		MaxConns:              uint32(conf.Directsearch.Connections),
+		IdleTimeout:           30 * time.Second,
+		HealthCheck:           true,
+		MaxConnErrors:         3,
+		MaxTooManyConnsErrors: 0,
+	}, 0)
+	if err != nil {
+		return false, err
+	}
+	pool = newPool
+	return true, nil
+}
+
+// closes the nntp pool so it can be initialized again
+// must only be called by the owner of the pool
+func closeNntpPool() {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	if pool != nil {
+		pool.Close()
+		pool = nil
+	}
+}
+
+// returns the current nntp pool
+func currentNntpPool() (nntpPool.ConnectionPool, error) {
+	poolMutex.Lock()
+	defer poolMutex.Unlock()
+	if pool == nil {
+		return nil, errors.New("the connection pool is closed")
+	}
+	return pool, nil
+}
+
+// returns the body of the article from the news server
+func fetchArticle(ctx context.Context, messageID string) (io.Reader, error) {
+	pool, err := currentNntpPool()
+	if err != nil {
+		return nil, err
+	}
+	var lastError error
+	for range 3 {
+		conn, err := pool.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		body, err := conn.Body("<" + messageID + ">")
+		if err == nil {
+			// the body must be read completely before the connection can be used again
+			var data []byte
+			if data, err = io.ReadAll(body); err == nil {
+				pool.Put(conn)
+				return bytes.NewReader(data), nil
+			}
+		}
+		var nntpError nntp.Error
+		if errors.As(err, &nntpError) {
+			pool.Put(conn)
+			if nntpError.Code == 430 || nntpError.Code == 423 {
+				return nil, errArticleNotFound
+			}
+			return nil, err
+		}
+		// connection error: discard the connection and try again
+		Log.Debug("Error while fetching article <%s>: %s", messageID, err.Error())
+		conn.Close()
+		pool.Put(conn)
+		lastError = err
+	}
+	return nil, lastError
+}
+
+func startNntpPoolLogger() {
 
 	go func() {
 		for {
@@ -34,6 +138,10 @@ func initNntpPool() error {
 	go func() {
 		for {
 			time.Sleep(5 * time.Second)
+			pool, err := currentNntpPool()
+			if err != nil {
+				continue
+			}
 			used, total := pool.Conns()
 			Log.Debug("NNTPPool: %d of %d connections in use", used, total)
 			if total > maxConn {
@@ -42,23 +150,4 @@ func initNntpPool() error {
 		}
 	}()
 
-	pool, err = nntpPool.New(&nntpPool.Config{
-		Name:                  "",
-		Host:                  conf.Directsearch.Host,
-		Port:                  uint32(conf.Directsearch.Port),
-		SSL:                   conf.Directsearch.SSL,
-		SkipSSLCheck:          true,
-		User:                  conf.Directsearch.Username,
-		Pass:                  conf.Directsearch.Password,
-		ConnWaitTime:          time.Duration(10) * time.Second,
-		MaxConns:              uint32(conf.Directsearch.Connections),
-		IdleTimeout:           30 * time.Second,
-		HealthCheck:           true,
-		MaxConnErrors:         3,
-		MaxTooManyConnsErrors: 0,
-	}, 0)
-	if err != nil {
-		return err
-	}
-	return nil
 }
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// d-bus message types
+const (
+	dbusMethodCall   = 1
+	dbusMethodReturn = 2
+	dbusError        = 3
+)
+
+// notifier functions for desktop notifications
+// the notifications are sent to org.freedesktop.Notifications on the session bus
+// function to create a notifier instance from a configuration section
+func desktop_newNotifier(name string, section *ini.Section) (Notifier, error) {
+	cfg := DesktopNotifier{Name: name, Timeout: -1}
+	if err := section.MapTo(&cfg); err != nil {
+		return Notifier{}, err
+	}
+	return Notifier{
+		name: name,
+		send: func(n notification) error {
+			return desktop_send(cfg, n)
+		},
+	}, nil
+}
+
+// function to show the notification on the desktop
+func desktop_send(cfg DesktopNotifier, n notification) error {
+
+	conn, err := dbus_connect(os.Getenv("DBUS_SESSION_BUS_ADDRESS"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// the first message must be Hello
+	hello := dbus_message(1, "org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "", nil)
+	if _, err := conn.Write(hello); err != nil {
+		return err
+	}
+
+	// Notify(app_name, replaces_id, app_icon, summary, body, actions, hints, expire_timeout)
+	var body dbusEncoder
+	body.string(appName)
+	body.uint32(0)
+	body.string("")
+	body.string(n.Subject)
+	body.string(n.Message)
+	body.align(4)
+	body.uint32(0) // no actions
+	body.align(4)
+	body.uint32(0) // no hints
+	body.align(8)
+	timeout := int32(cfg.Timeout)
+	if cfg.Timeout > 0 {
+		timeout = int32(cfg.Timeout * 1000)
+	}
+	body.uint32(uint32(timeout))
+	notify := dbus_message(2, "org.freedesktop.Notifications", "/org/freedesktop/Notifications", "org.freedesktop.Notifications", "Notify", "susssasa{sv}i", body.Bytes())
+	if _, err := conn.Write(notify); err != nil {
+		return err
+	}
+
+	// wait for the reply of the Notify call
+	for {
+		messageType, replySerial, errorName, err := dbus_readMessage(reader)
+		if err != nil {
+			return err
+		}
+		if replySerial != 2 {
+			continue
+		}
+		if messageType == dbusError {
+			return fmt.Errorf("d-bus error %s", errorName)
+		}
+		if messageType == dbusMethodReturn {
+			return nil
+		}
+	}
+
+}
+
+// connects and authenticates to the bus of the address (e.g. "unix:path=/run/user/1000/bus")
+func dbus_connect(address string) (net.Conn, error) {
+	if address == "" {
+		address = fmt.Sprintf("unix:path=/run/user/%d/bus", os.Getuid())
+	}
+	var conn net.Conn
+	err := fmt.Errorf("no supported d-bus address '%s'", address)
+	for entry := range strings.SplitSeq(address, ";") {
+		transport, options, _ := strings.Cut(entry, ":")
+		if transport != "unix" {
+			continue
+		}
+		for option := range strings.SplitSeq(options, ",") {
+			if key, value, _ := strings.Cut(option, "="); key == "path" {
+				conn, err = net.DialTimeout("unix", value, 10*time.Second)
+			} else if key == "abstract" {
+				conn, err = net.DialTimeout("unix", "@"+value, 10*time.Second)
+			}
+		}
+		if conn != nil {
+			break
+		}
+	}
+	if conn == nil {
+		return nil, err
+	}
+
+	// authenticate with the user id
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	line, err := bufio.NewReader(io.LimitReader(conn, 512)).ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		conn.Close()
+		return nil, fmt.Errorf("d-bus authentication failed: %s", strings.TrimSpace(line))
+	}
+	if _, err := conn.Write([]byte("BEGIN\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// encoder for little endian d-bus values
+// the alignment is relative to the start of the buffer
+type dbusEncoder struct {
+	bytes.Buffer
+}
+
+func (e *dbusEncoder) align(n int) {
+	for e.Len()%n != 0 {
+		e.WriteByte(0)
+	}
+}
+
+func (e *dbusEncoder) uint32(value uint32) {
+	e.align(4)
+	binary.Write(e, binary.LittleEndian, value)
+}
+
+func (e *dbusEncoder) string(value string) {
+	e.uint32(uint32(len(value)))
+	e.WriteString(value)
+	e.WriteByte(0)
+}
+
+func (e *dbusEncoder) signature(value string) {
+	e.WriteByte(byte(len(value)))
+	e.WriteString(value)
+	e.WriteByte(0)
+}
+
+// returns a method call message
+func dbus_message(serial uint32, destination string, path string, iface string, member string, signature string, body []byte) []byte {
+	var message dbusEncoder
+	message.Write([]byte{'l', dbusMethodCall, 0, 1})
+	message.uint32(uint32(len(body)))
+	message.uint32(serial)
+
+	var fields dbusEncoder
+	field := func(code byte, valueType string, value string) {
+		fields.align(8)
+		fields.WriteByte(code)
+		fields.signature(valueType)
+		if valueType == "g" {
+			fields.signature(value)
+		} else {
+			fields.string(value)
+		}
+	}
+	field(1, "o", path)
+	field(2, "s", iface)
+	field(3, "s", member)
+	field(6, "s", destination)
+	if signature != "" {
+		field(8, "g", signature)
+	}
+	// the fields are aligned relative to the message which starts 16 bytes before them
+	message.uint32(uint32(fields.Len()))
+	message.Write(fields.Bytes())
+	message.align(8)
+	message.Write(body)
+	return message.Bytes()
+}
+
+// reads a message and returns the type, the reply serial and the error name
+func dbus_readMessage(r *bufio.Reader) (byte, uint32, string, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, "", err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[0] == 'B' {
+		order = binary.BigEndian
+	}
+	bodyLength := order.Uint32(header[4:8])
+	fieldsLength := order.Uint32(header[12:16])
+	if fieldsLength > 1<<20 || bodyLength > 1<<24 {
+		return 0, 0, "", fmt.Errorf("invalid d-bus message")
+	}
+	// the fields are followed by padding to 8 bytes and the body
+	padding := (8 - (16+fieldsLength)%8) % 8
+	rest := make([]byte, fieldsLength+padding+bodyLength)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, "", err
+	}
+	fields := rest[:fieldsLength]
+
+	var replySerial uint32
+	var errorName string
+	// offsets are relative to the message start
+	for offset := 0; offset < len(fields); {
+		offset += (8 - (16+offset)%8) % 8
+		if offset+3 > len(fields) {
+			break
+		}
+		code := fields[offset]
+		signatureLength := int(fields[offset+1])
+		if offset+2+signatureLength+1 > len(fields) {
+			break
+		}
+		valueType := string(fields[offset+2 : offset+2+signatureLength])
+		offset += 2 + signatureLength + 1
+		switch valueType {
+		case "u":
+			offset += (4 - (16+offset)%4) % 4
+			if offset+4 > len(fields) {
+				return 0, 0, "", fmt.Errorf("invalid d-bus message")
+			}
+			if code == 5 {
+				replySerial = order.Uint32(fields[offset:])
+			}
+			offset += 4
+		case "s", "o":
+			offset += (4 - (16+offset)%4) % 4
+			if offset+4 > len(fields) {
+				return 0, 0, "", fmt.Errorf("invalid d-bus message")
+			}
+			length := int(order.Uint32(fields[offset:]))
+			if offset+4+length > len(fields) {
+				return 0, 0, "", fmt.Errorf("invalid d-bus message")
+			}
+			if code == 4 {
+				errorName = string(fields[offset+4 : offset+4+length])
+			}
+			offset += 4 + length + 1
+		case "g":
+			if offset >= len(fields) {
+				return 0, 0, "", fmt.Errorf("invalid d-bus message")
+			}
+			offset += 1 + int(fields[offset]) + 1
+		default:
+			return 0, 0, "", fmt.Errorf("unsupported d-bus header field type '%s'", valueType)
+		}
+	}
+	return header[1], replySerial, errorName, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+
+	"gopkg.in/ini.v1"
+)
+
+// desktop notifications are only supported on Linux (org.freedesktop.Notifications)
+func desktop_newNotifier(name string, section *ini.Section) (Notifier, error) {
+	return Notifier{}, fmt.Errorf("desktop notifications are only supported on Linux")
+}
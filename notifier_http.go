@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// notifier functions for HTTP notification services
+// function to create a notifier instance from a configuration section
+func http_newNotifier(name string, section *ini.Section) (Notifier, error) {
+	cfg := HTTPNotifier{Name: name, Format: "json"}
+	if err := section.MapTo(&cfg); err != nil {
+		return Notifier{}, err
+	}
+	if cfg.URL == "" {
+		return Notifier{}, fmt.Errorf("no url defined")
+	}
+	cfg.Format = strings.ToLower(cfg.Format)
+	if cfg.Format != "ntfy" && cfg.Format != "gotify" && cfg.Format != "apprise" && cfg.Format != "json" {
+		return Notifier{}, fmt.Errorf("unknown format '%s'", cfg.Format)
+	}
+	cfg.Headers = make(map[string]string)
+	for _, key := range section.Keys() {
+		if header, ok := strings.CutPrefix(key.Name(), "header."); ok && header != "" {
+			cfg.Headers[header] = key.Value()
+		}
+	}
+	return Notifier{
+		name: name,
+		send: func(n notification) error {
+			return http_send(cfg, n)
+		},
+	}, nil
+}
+
+// function to send the notification in the payload format of the service
+// ntfy:    message as body and the subject, priority and tags as headers (url of the topic)
+// gotify:  JSON message to <url>/message with the token as X-Gotify-Key
+// apprise: JSON notification to the notify endpoint (e.g. http://localhost:8000/notify/<key>)
+// json:    the notification as JSON object
+func http_send(cfg HTTPNotifier, n notification) error {
+	headers := make(map[string]string)
+	for key, value := range cfg.Headers {
+		headers[key] = value
+	}
+	url := cfg.URL
+	var body []byte
+	var err error
+	contentType := "application/json"
+
+	switch cfg.Format {
+	case "ntfy":
+		headers["Title"] = n.Subject
+		headers["Priority"] = "default"
+		headers["Tags"] = "white_check_mark"
+		if n.isFailure() {
+			headers["Priority"], headers["Tags"] = "high", "x"
+		} else if n.Event == eventIncomplete {
+			headers["Tags"] = "warning"
+		}
+		if cfg.Token != "" {
+			headers["Authorization"] = "Bearer " + cfg.Token
+		}
+		body, contentType = []byte(n.Message), "text/plain; charset=utf-8"
+	case "gotify":
+		priority := 5
+		if n.isFailure() {
+			priority = 8
+		}
+		url = strings.TrimSuffix(url, "/") + "/message"
+		if cfg.Token != "" {
+			headers["X-Gotify-Key"] = cfg.Token
+		}
+		body, err = json.Marshal(map[string]any{"title": n.Subject, "message": n.Message, "priority": priority})
+	case "apprise":
+		notificationType := "success"
+		if n.isFailure() {
+			notificationType = "failure"
+		} else if n.Event == eventIncomplete {
+			notificationType = "warning"
+		}
+		if cfg.Token != "" {
+			headers["Authorization"] = "Bearer " + cfg.Token
+		}
+		body, err = json.Marshal(map[string]string{"title": n.Subject, "body": n.Message, "type": notificationType})
+	default:
+		if cfg.Token != "" {
+			headers["Authorization"] = "Bearer " + cfg.Token
+		}
+		body, err = json.Marshal(n)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = postURLWithHeaders(url, bytes.NewReader(body), contentType, headers)
+	var statusError *httpStatusError
+	if errors.As(err, &statusError) && statusError.statusCode >= 200 && statusError.statusCode < 300 {
+		// e.g. 202 Accepted or 204 No Content
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// mqtt 3.1.1 packet types
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPuback     = 0x40
+	mqttDisconnect = 0xe0
+)
+
+// notifier functions for MQTT brokers
+// function to create a notifier instance from a configuration section
+func mqtt_newNotifier(name string, section *ini.Section) (Notifier, error) {
+	cfg := MQTTNotifier{Name: name, Topic: "nzb-monkey-go/{event}", ClientID: "nzb-monkey-go", Timeout: 30}
+	if err := section.MapTo(&cfg); err != nil {
+		return Notifier{}, err
+	}
+	if cfg.Broker == "" {
+		return Notifier{}, fmt.Errorf("no broker defined")
+	}
+	if cfg.Qos != 0 && cfg.Qos != 1 {
+		return Notifier{}, fmt.Errorf("unsupported qos %d (0 or 1)", cfg.Qos)
+	}
+	if _, _, err := mqtt_address(cfg.Broker); err != nil {
+		return Notifier{}, err
+	}
+	return Notifier{
+		name: name,
+		send: func(n notification) error {
+			return mqtt_send(cfg, n)
+		},
+	}, nil
+}
+
+// returns the address of the broker and true if TLS is used
+// the broker is given as tcp://host:port, mqtt://host:port, ssl://host:port, mqtts://host:port or host:port
+func mqtt_address(broker string) (string, bool, error) {
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	parsedURL, err := url.Parse(broker)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid broker: %s", err.Error())
+	}
+	var useTLS bool
+	port := "1883"
+	switch parsedURL.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS, port = true, "8883"
+	default:
+		return "", false, fmt.Errorf("invalid broker: unknown scheme '%s'", parsedURL.Scheme)
+	}
+	if parsedURL.Port() != "" {
+		port = parsedURL.Port()
+	}
+	return net.JoinHostPort(parsedURL.Hostname(), port), useTLS, nil
+}
+
+// function to publish the notification as JSON to the topic
+// "{event}" in the topic is replaced with the event
+func mqtt_send(cfg MQTTNotifier, n notification) error {
+
+	address, useTLS, err := mqtt_address(cfg.Broker)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.Timeout) * time.Second}
+	var conn net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host, InsecureSkipVerify: cfg.SkipCheck})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(cfg.Timeout) * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// connect
+	var connect bytes.Buffer
+	mqtt_writeString(&connect, "MQTT")
+	flags := byte(0x02) // clean session
+	if cfg.Username != "" {
+		flags |= 0x80
+		if cfg.Password != "" {
+			flags |= 0x40
+		}
+	}
+	connect.Write([]byte{4, flags, 0, 30}) // protocol level 4 and keep alive of 30 seconds
+	mqtt_writeString(&connect, cfg.ClientID)
+	if cfg.Username != "" {
+		mqtt_writeString(&connect, cfg.Username)
+		if cfg.Password != "" {
+			mqtt_writeString(&connect, cfg.Password)
+		}
+	}
+	if err := mqtt_writePacket(conn, mqttConnect, connect.Bytes()); err != nil {
+		return err
+	}
+	packetType, data, err := mqtt_readPacket(reader)
+	if err != nil {
+		return err
+	}
+	if packetType != mqttConnack || len(data) < 2 {
+		return fmt.Errorf("unexpected response of the broker")
+	}
+	if data[1] != 0 {
+		return fmt.Errorf("connection refused by the broker (return code %d)", data[1])
+	}
+
+	// publish
+	var publish bytes.Buffer
+	mqtt_writeString(&publish, strings.ReplaceAll(cfg.Topic, "{event}", n.Event))
+	header := byte(mqttPublish)
+	if cfg.Qos == 1 {
+		header |= 0x02
+		publish.Write([]byte{0, 1}) // packet identifier
+	}
+	if cfg.Retain {
+		header |= 0x01
+	}
+	publish.Write(payload)
+	if err := mqtt_writePacket(conn, header, publish.Bytes()); err != nil {
+		return err
+	}
+	if cfg.Qos == 1 {
+		packetType, _, err := mqtt_readPacket(reader)
+		if err != nil {
+			return err
+		}
+		if packetType != mqttPuback {
+			return fmt.Errorf("the broker did not acknowledge the message")
+		}
+	}
+
+	return mqtt_writePacket(conn, mqttDisconnect, nil)
+
+}
+
+// writes a length prefixed string
+func mqtt_writeString(buffer *bytes.Buffer, value string) {
+	binary.Write(buffer, binary.BigEndian, uint16(len(value)))
+	buffer.WriteString(value)
+}
+
+// writes a packet with the fixed header and the remaining length
+func mqtt_writePacket(w io.Writer, header byte, data []byte) error {
+	packet := []byte{header}
+	length := len(data)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(packet, data...))
+	return err
+}
+
+// reads a packet and returns the packet type and the data after the fixed header
+func mqtt_readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		if i == 4 {
+			return 0, nil, fmt.Errorf("invalid packet length")
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xf0, data, nil
+}
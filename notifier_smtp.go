@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// notifier functions for e-mail notifications
+// function to create a notifier instance from a configuration section
+func smtp_newNotifier(name string, section *ini.Section) (Notifier, error) {
+	cfg := SMTPNotifier{Name: name, Port: 587, Timeout: 30}
+	if err := section.MapTo(&cfg); err != nil {
+		return Notifier{}, err
+	}
+	if cfg.Host == "" {
+		return Notifier{}, fmt.Errorf("no host defined")
+	}
+	if cfg.From == "" || len(splitList(cfg.To)) == 0 {
+		return Notifier{}, fmt.Errorf("no sender or recipient defined")
+	}
+	return Notifier{
+		name: name,
+		send: func(n notification) error {
+			return smtp_send(cfg, n)
+		},
+	}, nil
+}
+
+// function to send the notification as e-mail
+// with ssl the connection is encrypted from the start (port 465), otherwise STARTTLS is used if the server supports it
+func smtp_send(cfg SMTPNotifier, n notification) error {
+
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.SkipCheck}
+	dialer := &net.Dialer{Timeout: time.Duration(cfg.Timeout) * time.Second}
+
+	var conn net.Conn
+	var err error
+	if cfg.Ssl {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(time.Duration(cfg.Timeout) * time.Second))
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !cfg.Ssl {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	recipients := splitList(cfg.To)
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(smtp_message(cfg.From, recipients, n)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+
+}
+
+// returns the e-mail message of the notification
+func smtp_message(from string, recipients []string, n notification) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body := []string{n.Message, ""}
+	if n.Title != "" {
+		body = append(body, "Title:    "+n.Title)
+	}
+	if n.Header != "" {
+		body = append(body, "Header:   "+n.Header)
+	}
+	if n.Category != "" {
+		body = append(body, "Category: "+n.Category)
+	}
+	if n.Engine != "" {
+		body = append(body, "Engine:   "+n.Engine)
+	}
+	message.WriteString(strings.Join(body, "\r\n") + "\r\n")
+	return message.Bytes()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// notification events
+const (
+	eventFound      = "found"       // the nzb file was pushed to at least one target
+	eventIncomplete = "incomplete"  // the nzb file is probably incomplete
+	eventNotFound   = "not_found"   // no nzb file was found
+	eventPushFailed = "push_failed" // the push to a target failed
+)
+
+var notificationEvents = []string{eventFound, eventIncomplete, eventNotFound, eventPushFailed}
+
+// subjects of the notifications per event
+var notificationSubjects = map[string]string{
+	eventFound:      "NZB file found",
+	eventIncomplete: "NZB file incomplete",
+	eventNotFound:   "No NZB file found",
+	eventPushFailed: "Push failed",
+}
+
+// notification sent to the notifiers
+type notification struct {
+	Event    string   `json:"event"`
+	Subject  string   `json:"subject"`
+	Message  string   `json:"message"`
+	Title    string   `json:"title"`
+	Header   string   `json:"header"`
+	Category string   `json:"category"`
+	Engine   string   `json:"engine"`
+	Targets  []string `json:"targets"`
+}
+
+// returns true for the events of failures
+func (n notification) isFailure() bool {
+	return n.Event == eventNotFound || n.Event == eventPushFailed
+}
+
+// notifier structure
+type Notifier struct {
+	name   string
+	events []string
+	send   func(notification) error
+}
+
+// global notifiers slice
+// will hold the notifiers defined in the configuration file in the order of the sections
+var notifiers []Notifier
+
+// notifier type structure
+type NotifierType struct {
+	name        string
+	newNotifier func(string, *ini.Section) (Notifier, error)
+}
+
+// notifier types map
+type NotifierTypes map[string]NotifierType
+
+// global notifier types map
+// the key is used for the "type" of a [NOTIFIER:<name>] section
+var notifierTypes = NotifierTypes{
+	"DESKTOP": NotifierType{
+		name:        "Desktop",
+		newNotifier: desktop_newNotifier,
+	},
+	"HTTP": NotifierType{
+		name:        "HTTP",
+		newNotifier: http_newNotifier,
+	},
+	"SMTP": NotifierType{
+		name:        "E-mail",
+		newNotifier: smtp_newNotifier,
+	},
+	"MQTT": NotifierType{
+		name:        "MQTT",
+		newNotifier: mqtt_newNotifier,
+	},
+}
+
+// function to load the notifiers from the [NOTIFIER:<name>] sections of the configuration file
+func loadNotifiers(cfg *ini.File) {
+	for _, section := range cfg.Sections() {
+		instance, ok := strings.CutPrefix(section.Name(), "NOTIFIER:")
+		if !ok || instance == "" {
+			continue
+		}
+		notifierType := strings.ToUpper(strings.TrimSpace(section.Key("type").String()))
+		if _, ok := notifierTypes[notifierType]; !ok {
+			Log.Warn("Unknown type '%s' for notifier '%s'", notifierType, instance)
+			continue
+		}
+		name := fmt.Sprintf("%s (%s)", notifierTypes[notifierType].name, instance)
+		notifier, err := notifierTypes[notifierType].newNotifier(name, section)
+		if err != nil {
+			Log.Warn("Unable to load notifier '%s': %s", instance, err.Error())
+			continue
+		}
+		notifier.events = notificationEvents
+		if section.HasKey("events") {
+			notifier.events = nil
+			for _, event := range splitList(strings.ToLower(section.Key("events").String())) {
+				if !slices.Contains(notificationEvents, event) {
+					Log.Warn("Unknown event '%s' for notifier '%s'", event, instance)
+					continue
+				}
+				notifier.events = append(notifier.events, event)
+			}
+		}
+		notifiers = append(notifiers, notifier)
+	}
+}
+
+// function to send a notification for the event to the notifiers
+// errors of the notifiers are only logged
+func notify(event string, result *Result, category string, targets []string, message string) {
+	n := notification{
+		Event:    event,
+		Subject:  fmt.Sprintf("%s: %s", appName, notificationSubjects[event]),
+		Message:  redact(message),
+		Title:    args.Title,
+		Header:   args.Header,
+		Category: category,
+		Targets:  targets,
+	}
+	if result != nil {
+		n.Engine = result.SearchEngine
+	}
+	for _, notifier := range notifiers {
+		if !slices.Contains(notifier.events, event) {
+			continue
+		}
+		if err := notifier.send(n); err != nil {
+			Log.Warn("Unable to send the notification with %s: %s", notifier.name, err.Error())
+		} else {
+			Log.Debug("Notification sent with %s", notifier.name)
+		}
+	}
+}
+
+// returns the display names of the targets as comma separated list
+func targetNames(names []string) string {
+	var displayNames []string
+	for _, name := range names {
+		displayNames = append(displayNames, targets[name].name)
+	}
+	return strings.Join(displayNames, ", ")
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/Tensai75/fslock"
 	"github.com/Tensai75/nntpDirectSearch"
+	"github.com/Tensai75/nntpPool"
 	"github.com/Tensai75/nzbparser"
 	progressbar "github.com/schollz/progressbar/v3"
 )
@@ -83,16 +84,21 @@ func nzbdirectsearch(engine SearchEngine, name string) error {
 	}
 
 	// initialize nntp pool
-	if err := initNntpPool(); err != nil {
+	// the pool is only closed if it was initialized by the search
+	if owned, err := initNntpPool(); err != nil {
+		return err
+	} else if owned {
+		defer closeNntpPool()
+	}
+	var connections nntpPool.ConnectionPool
+	if connections, err = currentNntpPool(); err != nil {
 		return err
-	} else {
-		defer pool.Close()
 	}
 
 	// initialize direct search
 	directSearchCtx, directSearchCtxCancel := context.WithCancel(context.Background())
 	defer directSearchCtxCancel()
-	directSearch, err = nntpDirectSearch.New(pool, directSearchCtx)
+	directSearch, err = nntpDirectSearch.New(connections, directSearchCtx)
 	if err != nil {
 		return err
 	}
@@ -135,7 +141,7 @@ func nzbdirectsearch(engine SearchEngine, name string) error {
 
 		nzbFiles, searchInGroupError := searchInGroup(group)
 		if searchInGroupError != nil {
-			Log.Error(searchInGroupError.Error())
+			Log.Error("%s", searchInGroupError.Error())
 			continue
 		}
 		if len(nzbFiles) == 0 {
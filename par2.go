@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Tensai75/nzbparser"
+)
+
+// file types of a post
+const (
+	dataFile = iota
+	par2IndexFile
+	par2VolumeFile
+)
+
+var (
+	par2PacketMagic   = []byte("PAR2\x00PKT")
+	par2MainType      = []byte("PAR 2.0\x00Main\x00\x00\x00\x00")
+	par2FileDescType  = []byte("PAR 2.0\x00FileDesc")
+	par2PacketHeader  = 64
+	par2MainBody      = 12 // block size and number of files followed by the file ids
+	par2FileDescBody  = 56 // file id, hashes and length followed by the name
+	par2HashBlockSize = 16384
+
+	par2VolumeRegexp = regexp.MustCompile(`(?i)\.vol(\d+)\+(\d+)\.par2$`)
+	par2IndexRegexp  = regexp.MustCompile(`(?i)\.par2$`)
+	subjectNameRegex = regexp.MustCompile(`"([^"]+)"`)
+)
+
+// returns the filename of a file either parsed from the subject by the nzbparser or quoted in the subject
+func getFileName(file nzbparser.NzbFile) string {
+	if file.Filename != "" {
+		return file.Filename
+	}
+	if match := subjectNameRegex.FindStringSubmatch(file.Subject); match != nil {
+		return match[1]
+	}
+	return file.Subject
+}
+
+// returns the file type and for par2 volumes the number of recovery blocks
+func classifyFile(file nzbparser.NzbFile) (int, int) {
+	name := getFileName(file)
+	if match := par2VolumeRegexp.FindStringSubmatch(name); match != nil {
+		blocks, _ := strconv.Atoi(match[2])
+		return par2VolumeFile, blocks
+	}
+	if par2IndexRegexp.MatchString(name) {
+		return par2IndexFile, 0
+	}
+	return dataFile, 0
+}
+
+// par2 index of a post read from the main and the file description packets of a par2 file
+type par2Index struct {
+	BlockSize int64
+	Files     []par2File // files of the recovery set
+}
+
+// file description of a par2 file
+type par2File struct {
+	ID      [16]byte
+	Hash16k [16]byte // md5 hash of the first 16 kB of the file
+	Length  int64
+	Name    string
+}
+
+// returns the par2 index of the par2 data or nil if the data contains no main or file description packet
+// damaged packets are skipped
+func parsePar2Index(data []byte) *par2Index {
+
+	var blockSize int64
+	var recoverySet [][16]byte
+	var files []par2File
+	for offset := 0; ; {
+		next := bytes.Index(data[offset:], par2PacketMagic)
+		if next < 0 {
+			break
+		}
+		offset += next
+		if offset+par2PacketHeader > len(data) {
+			break
+		}
+		length := binary.LittleEndian.Uint64(data[offset+8:])
+		if length < uint64(par2PacketHeader) || length > uint64(len(data)-offset) || !bytes.Equal(par2PacketHash(data[offset:offset+int(length)]), data[offset+16:offset+32]) {
+			// damaged packet: continue with the next packet
+			offset++
+			continue
+		}
+		packet := data[offset : offset+int(length)]
+		body := packet[par2PacketHeader:]
+		switch {
+		case bytes.Equal(packet[48:64], par2MainType) && len(body) >= par2MainBody:
+			blockSize = int64(binary.LittleEndian.Uint64(body[0:8]))
+			count := int(binary.LittleEndian.Uint32(body[8:12]))
+			for i := 0; i < count && par2MainBody+(i+1)*16 <= len(body); i++ {
+				var id [16]byte
+				copy(id[:], body[par2MainBody+i*16:])
+				recoverySet = append(recoverySet, id)
+			}
+		case bytes.Equal(packet[48:64], par2FileDescType) && len(body) >= par2FileDescBody:
+			var file par2File
+			copy(file.ID[:], body[0:16])
+			copy(file.Hash16k[:], body[32:48])
+			file.Length = int64(binary.LittleEndian.Uint64(body[48:56]))
+			file.Name = strings.TrimRight(string(body[par2FileDescBody:]), "\x00")
+			if !slices.ContainsFunc(files, func(f par2File) bool { return f.ID == file.ID }) {
+				files = append(files, file)
+			}
+		}
+		offset += int(length)
+	}
+	if blockSize == 0 && len(files) == 0 {
+		return nil
+	}
+
+	// only the files of the recovery set can be repaired
+	if len(recoverySet) > 0 {
+		files = slices.DeleteFunc(files, func(f par2File) bool { return !slices.Contains(recoverySet, f.ID) })
+	}
+	return &par2Index{BlockSize: blockSize, Files: files}
+
+}
+
+// returns the md5 hash of a packet which covers the packet from the recovery set id to the end
+func par2PacketHash(packet []byte) []byte {
+	hash := md5.Sum(packet[32:])
+	return hash[:]
+}
+
+// function to estimate if the missing segments of a result can be repaired with the available par2 recovery blocks
+// the missing files are classified with the file descriptions of the par2 index if it was read,
+// else with the announced file numbers of the subjects ([i/n]) as par2 files are posted together
+// the block size is taken from the par2 index or estimated from the size of the complete par2 volumes
+func evaluatePar2(result *Result) {
+
+	result.Par2Checked = false
+	result.RecoveryBlocks = 0
+	result.DamagedBlocks = 0
+	result.Par2FilesMissing = 0
+
+	types := make([]int, result.Nzb.Files.Len())
+	blocks := make([]int, result.Nzb.Files.Len())
+	hasPar2 := false
+	for i, file := range result.Nzb.Files {
+		types[i], blocks[i] = classifyFile(file)
+		hasPar2 = hasPar2 || types[i] != dataFile
+	}
+	index := result.Par2Index
+	if !hasPar2 && index == nil {
+		return
+	}
+
+	// classify the files missing in the NZB file
+	missingData, missingPar2 := classifyMissingFiles(result.Nzb, types, index)
+	result.Par2FilesMissing = missingPar2
+
+	// block size of the par2 index or the estimate of the largest complete par2 volume
+	var blockSize float64
+	if index != nil && index.BlockSize > 0 {
+		blockSize = float64(index.BlockSize)
+	} else {
+		var volumeBlocks int
+		for i, file := range result.Nzb.Files {
+			unavailable := 0
+			if i < len(result.FilesUnavailable) {
+				unavailable = result.FilesUnavailable[i]
+			}
+			if types[i] == par2VolumeFile && blocks[i] > volumeBlocks && file.Segments.Len() == file.TotalSegments && unavailable == 0 {
+				blockSize, volumeBlocks = float64(file.Bytes)/float64(blocks[i]), blocks[i]
+			}
+		}
+	}
+
+	// returns the number of blocks of the given bytes (at least one block if the block size is unknown)
+	blocksOf := func(bytes float64) int {
+		if blockSize <= 0 {
+			return 1
+		}
+		return max(int(math.Ceil(bytes/blockSize)), 1)
+	}
+
+	var dataBytes int64
+	var dataFiles int
+	for i, file := range result.Nzb.Files {
+		missing := file.TotalSegments - file.Segments.Len()
+		if i < len(result.FilesUnavailable) {
+			missing += result.FilesUnavailable[i]
+		}
+		missing = min(missing, file.TotalSegments)
+		damaged := 0
+		if missing > 0 && file.Segments.Len() > 0 {
+			// a missing segment damages every block it overlaps
+			segmentSize := float64(file.Bytes) / float64(file.Segments.Len())
+			damaged = missing * (blocksOf(segmentSize) + 1)
+			if blockSize > 0 {
+				damaged = min(damaged, blocksOf(segmentSize*float64(file.TotalSegments)))
+			}
+		}
+		switch types[i] {
+		case par2VolumeFile:
+			// a damaged recovery block cannot be used
+			result.RecoveryBlocks += max(blocks[i]-damaged, 0)
+		case dataFile:
+			dataBytes += file.Bytes
+			dataFiles++
+			result.DamagedBlocks += damaged
+		}
+	}
+
+	// missing data files are completely damaged
+	for _, length := range missingData {
+		if length > 0 {
+			result.DamagedBlocks += blocksOf(float64(length))
+		} else if dataFiles > 0 {
+			// unknown size: average size of the data files
+			result.DamagedBlocks += blocksOf(float64(dataBytes) / float64(dataFiles))
+		} else {
+			result.DamagedBlocks++
+		}
+	}
+
+	result.Par2Checked = true
+	result.Repairable = result.DamagedBlocks <= result.RecoveryBlocks
+
+}
+
+// returns the sizes of the missing data files (0 if unknown) and the number of missing par2 files
+func classifyMissingFiles(nzb *nzbparser.Nzb, types []int, index *par2Index) ([]int64, int) {
+
+	missingFiles := nzb.TotalFiles - nzb.Files.Len()
+
+	// the data files of the par2 index which are not in the NZB file are missing
+	// this requires the real filenames in the NZB file (e.g. not obfuscated)
+	if index != nil && len(index.Files) > 0 {
+		names := make(map[string]bool)
+		for _, file := range nzb.Files {
+			names[strings.ToLower(getFileName(file))] = true
+		}
+		var missingData []int64
+		for _, file := range index.Files {
+			if !names[strings.ToLower(file.Name)] {
+				missingData = append(missingData, file.Length)
+			}
+		}
+		if len(missingData) < len(index.Files) {
+			return missingData, max(missingFiles-len(missingData), 0)
+		}
+	}
+	if missingFiles <= 0 {
+		return nil, 0
+	}
+
+	// the type of the announced files of the subjects ([i/n])
+	announced := make(map[int]int)
+	for i, file := range nzb.Files {
+		if file.Number > 0 {
+			announced[file.Number] = types[i]
+		}
+	}
+	if len(announced) == 0 {
+		// no file numbers: the missing files are assumed to be data files
+		return make([]int64, missingFiles), 0
+	}
+
+	// a missing file is a par2 file if the closest announced files before and after it are par2 files
+	var missingData []int64
+	var missingPar2 int
+	for number := 1; number <= nzb.TotalFiles; number++ {
+		if _, ok := announced[number]; ok {
+			continue
+		}
+		before, after := -1, -1
+		for n := number - 1; n >= 1 && before < 0; n-- {
+			if fileType, ok := announced[n]; ok {
+				before = fileType
+			}
+		}
+		for n := number + 1; n <= nzb.TotalFiles && after < 0; n++ {
+			if fileType, ok := announced[n]; ok {
+				after = fileType
+			}
+		}
+		if before != dataFile && after != dataFile {
+			missingPar2++
+		} else {
+			missingData = append(missingData, 0)
+		}
+	}
+	return missingData, missingPar2
+
+}
+
+func logPar2(result Result) {
+	if !result.Par2Checked {
+		return
+	}
+	if result.Par2FilesMissing > 0 {
+		Log.Warn("Par2:     %d par2 files missing", result.Par2FilesMissing)
+	}
+	if result.Repairable {
+		Log.Info("Par2:     %s", green(fmt.Sprintf("repairable (%d recovery blocks available, about %d blocks damaged)", result.RecoveryBlocks, result.DamagedBlocks)))
+	} else {
+		Log.Info("Par2:     %s", red(fmt.Sprintf("not repairable (%d recovery blocks available, about %d blocks damaged)", result.RecoveryBlocks, result.DamagedBlocks)))
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/Tensai75/nzbparser"
+)
+
+// returns a par2 packet of the type with the body and a valid packet hash
+func testPar2Packet(packetType []byte, body []byte) []byte {
+	packet := make([]byte, par2PacketHeader+len(body))
+	copy(packet, par2PacketMagic)
+	binary.LittleEndian.PutUint64(packet[8:], uint64(len(packet)))
+	copy(packet[48:], packetType)
+	copy(packet[par2PacketHeader:], body)
+	hash := md5.Sum(packet[32:])
+	copy(packet[16:], hash[:])
+	return packet
+}
+
+// returns a main packet with the block size and the file ids of the recovery set
+func testPar2Main(blockSize uint64, ids ...[16]byte) []byte {
+	body := make([]byte, par2MainBody)
+	binary.LittleEndian.PutUint64(body, blockSize)
+	binary.LittleEndian.PutUint32(body[8:], uint32(len(ids)))
+	for _, id := range ids {
+		body = append(body, id[:]...)
+	}
+	return testPar2Packet(par2MainType, body)
+}
+
+// returns a file description packet (the name is padded to a multiple of 4 bytes)
+func testPar2FileDesc(id [16]byte, name string, length uint64) []byte {
+	body := make([]byte, par2FileDescBody)
+	copy(body, id[:])
+	hash := md5.Sum([]byte(name))
+	copy(body[32:], hash[:])
+	binary.LittleEndian.PutUint64(body[48:], length)
+	body = append(body, name...)
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	return testPar2Packet(par2FileDescType, body)
+}
+
+func TestParsePar2Index(t *testing.T) {
+
+	idA, idB, idC := [16]byte{1}, [16]byte{2}, [16]byte{3}
+	main := testPar2Main(4096, idA, idB)
+	descA := testPar2FileDesc(idA, "file.part1.rar", 10000)
+	descB := testPar2FileDesc(idB, "file.part2.rar", 5000)
+	descC := testPar2FileDesc(idC, "file.nfo", 100)
+	damaged := append([]byte{}, descB...)
+	damaged[len(damaged)-5] ^= 0xff
+	concat := func(packets ...[]byte) []byte {
+		var data []byte
+		for _, packet := range packets {
+			data = append(data, packet...)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		blockSize int64
+		files     []string
+	}{
+		{"main and file descriptions", concat(main, descA, descB), 4096, []string{"file.part1.rar", "file.part2.rar"}},
+		{"duplicate packets", concat(main, descA, descB, main, descA), 4096, []string{"file.part1.rar", "file.part2.rar"}},
+		{"garbage between packets", concat([]byte("garbage"), main, []byte("PAR2\x00PKT"), descA), 4096, []string{"file.part1.rar"}},
+		{"damaged packet", concat(main, descA, damaged), 4096, []string{"file.part1.rar"}},
+		{"file not in the recovery set", concat(main, descA, descC), 4096, []string{"file.part1.rar"}},
+		{"without main packet", concat(descA, descC), 0, []string{"file.part1.rar", "file.nfo"}},
+		{"truncated packet", concat(main, descA[:len(descA)-8]), 4096, nil},
+		{"no packets", []byte("no par2 data"), 0, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index := parsePar2Index(test.data)
+			if test.blockSize == 0 && test.files == nil {
+				if index != nil {
+					t.Fatalf("got index %+v, want nil", index)
+				}
+				return
+			}
+			if index == nil {
+				t.Fatal("got nil index")
+			}
+			if index.BlockSize != test.blockSize {
+				t.Errorf("got block size %d, want %d", index.BlockSize, test.blockSize)
+			}
+			var names []string
+			for _, file := range index.Files {
+				names = append(names, file.Name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(test.files) {
+				t.Errorf("got files %v, want %v", names, test.files)
+			}
+		})
+	}
+
+	index := parsePar2Index(concat(main, descA))
+	if file := index.Files[0]; file.Length != 10000 || file.Hash16k != md5.Sum([]byte("file.part1.rar")) {
+		t.Errorf("got file %+v", file)
+	}
+
+}
+
+// file of a test post
+type testFile struct {
+	number   int
+	name     string
+	segments int // total segments
+	missing  int // missing segments
+}
+
+// returns a parsed nzb file with the files of the post
+func testPost(totalFiles int, files ...testFile) *nzbparser.Nzb {
+	nzb := &nzbparser.Nzb{}
+	for _, file := range files {
+		nzbFile := nzbparser.NzbFile{Subject: fmt.Sprintf("[%d/%d] - \"%s\" yEnc (1/%d)", file.number, totalFiles, file.name, file.segments)}
+		if file.number == 0 {
+			nzbFile.Subject = fmt.Sprintf("\"%s\" yEnc (1/%d)", file.name, file.segments)
+		}
+		for segment := 1; segment <= file.segments-file.missing; segment++ {
+			nzbFile.Segments = append(nzbFile.Segments, nzbparser.NzbSegment{Number: segment, Bytes: 1000, Id: fmt.Sprintf("%s-%d@test", file.name, segment)})
+		}
+		if file.missing > 0 {
+			// the last segment is available to keep the number of segments of the file
+			nzbFile.Segments[len(nzbFile.Segments)-1].Number = file.segments
+		}
+		nzb.Files = append(nzb.Files, nzbFile)
+	}
+	nzbparser.ScanNzbFile(nzb)
+	if nzb.TotalFiles < totalFiles {
+		nzb.TotalFiles = totalFiles
+	}
+	return nzb
+}
+
+func TestEvaluatePar2(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		nzb         *nzbparser.Nzb
+		index       *par2Index
+		checked     bool
+		repairable  bool
+		recovery    int
+		damaged     int
+		par2Missing int
+		unavailable []int
+	}{
+		{
+			name:    "no par2 files",
+			nzb:     testPost(2, testFile{1, "file.part1.rar", 10, 0}, testFile{2, "file.part2.rar", 10, 2}),
+			checked: false,
+		},
+		{
+			name: "complete post",
+			nzb: testPost(4, testFile{1, "file.part1.rar", 10, 0}, testFile{2, "file.part2.rar", 10, 0},
+				testFile{3, "file.par2", 1, 0}, testFile{4, "file.vol00+10.par2", 10, 0}),
+			checked: true, repairable: true, recovery: 10, damaged: 0,
+		},
+		{
+			name: "missing segments repairable",
+			nzb: testPost(4, testFile{1, "file.part1.rar", 10, 2}, testFile{2, "file.part2.rar", 10, 0},
+				testFile{3, "file.par2", 1, 0}, testFile{4, "file.vol00+10.par2", 10, 0}),
+			checked: true, repairable: true, recovery: 10, damaged: 4,
+		},
+		{
+			name: "only par2 volumes missing",
+			nzb: testPost(5, testFile{1, "file.part1.rar", 10, 0}, testFile{2, "file.part2.rar", 10, 0},
+				testFile{3, "file.par2", 1, 0}),
+			checked: true, repairable: true, recovery: 0, damaged: 0, par2Missing: 2,
+		},
+		{
+			name: "par2 volumes and segments missing",
+			nzb: testPost(5, testFile{1, "file.part1.rar", 10, 2}, testFile{2, "file.part2.rar", 10, 0},
+				testFile{3, "file.par2", 1, 0}),
+			checked: true, repairable: false, recovery: 0, damaged: 4, par2Missing: 2,
+		},
+		{
+			name: "missing data file repairable",
+			nzb: testPost(5, testFile{1, "file.part1.rar", 10, 0}, testFile{3, "file.part3.rar", 10, 0},
+				testFile{4, "file.par2", 1, 0}, testFile{5, "file.vol00+10.par2", 10, 0}),
+			checked: true, repairable: true, recovery: 10, damaged: 10,
+		},
+		{
+			name: "missing data file not repairable",
+			nzb: testPost(5, testFile{1, "file.part1.rar", 10, 0}, testFile{3, "file.part3.rar", 10, 0},
+				testFile{4, "file.par2", 1, 0}, testFile{5, "file.vol00+05.par2", 5, 0}),
+			checked: true, repairable: false, recovery: 5, damaged: 10,
+		},
+		{
+			name: "damaged par2 volume",
+			nzb: testPost(4, testFile{1, "file.part1.rar", 10, 0}, testFile{2, "file.part2.rar", 10, 0},
+				testFile{3, "file.par2", 1, 0}, testFile{4, "file.vol00+10.par2", 10, 2}),
+			checked: true, repairable: true, recovery: 6, damaged: 0,
+		},
+		{
+			name: "unavailable segments",
+			nzb: testPost(4, testFile{1, "file.part1.rar", 10, 0}, testFile{2, "file.part2.rar", 10, 0},
+				testFile{3, "file.par2", 1, 0}, testFile{4, "file.vol00+02.par2", 2, 0}),
+			unavailable: []int{3, 0, 0, 0},
+			checked:     true, repairable: false, recovery: 2, damaged: 6,
+		},
+		{
+			name: "missing files classified with the par2 index",
+			nzb: testPost(3, testFile{0, "file.part1.rar", 10, 0}, testFile{0, "file.par2", 1, 0},
+				testFile{0, "file.vol00+04.par2", 4, 0}),
+			index: &par2Index{BlockSize: 2000, Files: []par2File{
+				{Name: "file.part1.rar", Length: 10000},
+				{Name: "file.part2.rar", Length: 8000},
+			}},
+			checked: true, repairable: true, recovery: 4, damaged: 4,
+		},
+		{
+			name: "par2 volumes missing according to the par2 index",
+			nzb: testPost(4, testFile{1, "file.part1.rar", 10, 1}, testFile{2, "file.part2.rar", 8, 0},
+				testFile{3, "file.par2", 1, 0}),
+			index: &par2Index{BlockSize: 2000, Files: []par2File{
+				{Name: "file.part1.rar", Length: 10000},
+				{Name: "file.part2.rar", Length: 8000},
+			}},
+			checked: true, repairable: false, recovery: 0, damaged: 2, par2Missing: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Result{Nzb: test.nzb, Par2Index: test.index, FilesUnavailable: test.unavailable}
+			evaluatePar2(&result)
+			if result.Par2Checked != test.checked {
+				t.Fatalf("got checked %v, want %v", result.Par2Checked, test.checked)
+			}
+			if !test.checked {
+				return
+			}
+			if result.Repairable != test.repairable || result.RecoveryBlocks != test.recovery || result.DamagedBlocks != test.damaged || result.Par2FilesMissing != test.par2Missing {
+				t.Errorf("got repairable %v, recovery blocks %d, damaged blocks %d, par2 files missing %d, want %v, %d, %d, %d",
+					result.Repairable, result.RecoveryBlocks, result.DamagedBlocks, result.Par2FilesMissing,
+					test.repairable, test.recovery, test.damaged, test.par2Missing)
+			}
+		})
+	}
+
+}
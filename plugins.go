@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Tensai75/nzbparser"
+	"gopkg.in/ini.v1"
+)
+
+// maximum length of a line written by a plugin (an inline nzb file is one line)
+const pluginMaxLine = 64 * 1024 * 1024
+
+// plugin configuration structure
+type Plugin struct {
+	Command string `ini:"command"`
+	Name    string `ini:"name"`
+	Timeout int    `ini:"timeout"`
+}
+
+// query passed to the plugin as JSON on stdin
+type pluginQuery struct {
+	Header   string   `json:"header"`
+	Title    string   `json:"title"`
+	Password string   `json:"password"`
+	Groups   []string `json:"groups"`
+	Date     int64    `json:"date"`
+	Category string   `json:"category"`
+}
+
+// line written by the plugin as JSON on stdout
+// a line contains either a log message or a candidate nzb file (inline xml or path)
+type pluginMessage struct {
+	Log   string `json:"log"`
+	Level string `json:"level"`
+	Nzb   string `json:"nzb"`
+	File  string `json:"file"`
+}
+
+// function to register the search engine plugins of the [PLUGIN:<name>] sections
+// the plugins are enabled and ordered in the [SEARCHENGINES] section like the built-in search engines
+func loadPlugins(cfg *ini.File) {
+	for _, section := range cfg.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), "PLUGIN:")
+		if !ok || name == "" {
+			continue
+		}
+		if _, exists := searchEngines[name]; exists {
+			Log.Warn("Plugin '%s' has the same name as a search engine and is ignored", name)
+			continue
+		}
+		plugin := Plugin{Name: name, Timeout: 60}
+		if err := section.MapTo(&plugin); err != nil {
+			Log.Warn("Error in the plugin section '%s': %s", section.Name(), err.Error())
+			continue
+		}
+		if strings.TrimSpace(plugin.Command) == "" {
+			Log.Warn("Error in the plugin section '%s': no command defined", section.Name())
+			continue
+		}
+		searchEngines[name] = SearchEngine{
+			name:    plugin.Name,
+			command: plugin.Command,
+			timeout: plugin.Timeout,
+			search:  pluginSearch,
+		}
+	}
+}
+
+// search function for plugins
+// the plugin is executed with the query on stdin and the candidate nzb files returned on stdout are processed after it exits
+func pluginSearch(engine SearchEngine, name string) error {
+
+	input, err := json.Marshal(pluginQuery{
+		Header:   args.Header,
+		Title:    args.Title,
+		Password: args.Password,
+		Groups:   args.Groups,
+		Date:     args.UnixDate,
+		Category: args.Category,
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(max(engine.timeout, 1)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// relative paths of the command and of the returned files are relative to the directory of the configuration file
+	cmd := shellCommand(ctx, engine.command)
+	cmd.Dir = filepath.Dir(confPath)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to run the plugin: %s", err.Error())
+	}
+
+	var nzbs []*nzbparser.Nzb
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), pluginMaxLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var message pluginMessage
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			// plain text lines are shown as log messages
+			Log.Info("   %s", line)
+			continue
+		}
+		if message.Log != "" {
+			switch strings.ToLower(message.Level) {
+			case "debug":
+				Log.Debug("%s", message.Log)
+			case "warn", "warning":
+				Log.Warn("%s", message.Log)
+			case "error":
+				Log.Error("%s", message.Log)
+			default:
+				Log.Info("   %s", message.Log)
+			}
+		}
+		if message.Nzb != "" || message.File != "" {
+			if nzb, err := pluginNzb(message); err != nil {
+				Log.Warn("Invalid NZB file returned by the plugin: %s", err.Error())
+			} else {
+				nzbs = append(nzbs, nzb)
+			}
+		}
+	}
+	scanErr := scanner.Err()
+	err = cmd.Wait()
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("the plugin timed out after %s", timeout)
+	}
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		return fmt.Errorf("the plugin exited with exit code %d", exitError.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("error running the plugin: %s", err.Error())
+	}
+	if scanErr != nil {
+		return fmt.Errorf("error reading the plugin output: %s", scanErr.Error())
+	}
+	if len(nzbs) == 0 {
+		return fmt.Errorf("no results found")
+	}
+
+	for _, nzb := range nzbs {
+		processResult(nzb, name)
+	}
+	return nil
+}
+
+// returns the parsed nzb file of a plugin message
+func pluginNzb(message pluginMessage) (*nzbparser.Nzb, error) {
+	data := message.Nzb
+	if data == "" {
+		path := message.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(confPath), path)
+		}
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data = string(file)
+	}
+	nzb, err := nzbparser.ParseString(data)
+	if err != nil {
+		return nil, err
+	}
+	if nzb.Files.Len() == 0 {
+		return nil, fmt.Errorf("the NZB file is empty")
+	}
+	return nzb, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"cmp"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+// placeholder for redacted values
+const redacted = "[redacted]"
+
+// secrets replaced by the redaction (configured passwords, api keys and tokens and session ids)
+var (
+	secrets      []string
+	secretsMutex sync.RWMutex
+)
+
+// keys of the configuration file with secret values
+var secretKeys = []string{"password", "pass", "passwd", "basicauth_password", "nzbkey", "apikey", "api_key", "token", "bearer_token", "secret", "otp_secret"}
+
+// parameters with secret values in urls, forms and JSON (e.g. apikey=..., "password": "...")
+var secretParametersRegexp = regexp.MustCompile(`(?i)\b((?:apikey|api_key|nzbkey|passwd|password|pass|token|access_token|secret|_sid|sid|otp_code)(?:=|"\s*:\s*"))[^&\s"]+`)
+
+// secret values after a label at the start of a line (e.g. "Password: ...") up to the end of the line or the next color code
+var secretLabelsRegexp = regexp.MustCompile(`(?im)^([ \t]*(?:password|api key|apikey|token|secret):[ \t]+(?:\x1b\[[0-9;]*m)*)[^\x1b\r\n]+`)
+
+// color codes at the end of a text
+var trailingColorRegexp = regexp.MustCompile(`(?:\x1b\[[0-9;]*m)+$`)
+
+// function to register a secret value
+// values shorter than 4 characters are ignored as they would redact too much of the text
+// and are only redacted after their label or parameter name
+func addSecret(value string) {
+	if len(value) < 4 {
+		return
+	}
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	for _, variant := range []string{value, url.QueryEscape(value), url.PathEscape(value)} {
+		if !slices.Contains(secrets, variant) {
+			secrets = append(secrets, variant)
+		}
+	}
+	// replace longer secrets first in case a secret contains another one
+	slices.SortFunc(secrets, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+}
+
+// function to register the values of the secret keys of the configuration file
+// header.<name> keys of the webhooks and notifiers are secret if the name contains key, token, auth or secret
+func addConfigSecrets(cfg *ini.File) {
+	for _, section := range cfg.Sections() {
+		for _, key := range section.Keys() {
+			name := strings.ToLower(key.Name())
+			if header, ok := strings.CutPrefix(name, "header."); ok {
+				if strings.Contains(header, "key") || strings.Contains(header, "token") || strings.Contains(header, "auth") || strings.Contains(header, "secret") {
+					addSecret(key.Value())
+				}
+			} else if slices.Contains(secretKeys, name) {
+				addSecret(key.Value())
+			}
+		}
+	}
+}
+
+// returns the text with the secrets and the password of the nzb file replaced
+// used for the console, the log file, the HTTP traces and the notifications
+func redact(text string) string {
+	secretsMutex.RLock()
+	for _, secret := range secrets {
+		text = replaceToken(text, secret)
+	}
+	secretsMutex.RUnlock()
+	if len(args.Password) >= 4 {
+		for _, variant := range []string{args.Password, url.QueryEscape(args.Password)} {
+			text = replaceToken(text, variant)
+		}
+	}
+	text = secretLabelsRegexp.ReplaceAllString(text, "${1}"+redacted)
+	return secretParametersRegexp.ReplaceAllString(text, "${1}"+redacted)
+}
+
+// returns the text with the occurrences of the secret replaced which are whole tokens
+// a secret within a longer word (e.g. a short password in a filename) is not replaced
+// a color code before the secret is not part of the token
+func replaceToken(text string, secret string) string {
+	var result strings.Builder
+	start := 0
+	for {
+		index := strings.Index(text[start:], secret)
+		if index < 0 {
+			result.WriteString(text[start:])
+			return result.String()
+		}
+		index += start
+		end := index + len(secret)
+		before := trailingColorRegexp.ReplaceAllString(text[:index], "")
+		if (before == "" || !isWordByte(before[len(before)-1])) && (end == len(text) || !isWordByte(text[end])) {
+			result.WriteString(text[start:index])
+			result.WriteString(redacted)
+		} else {
+			result.WriteString(text[start:end])
+		}
+		start = end
+	}
+}
+
+// returns true for letters, digits and the underscore
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || b >= 0x80
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// registers the secrets for the test and removes them afterwards
+func testSecrets(t *testing.T, values ...string) {
+	saved := secrets
+	secrets = nil
+	for _, value := range values {
+		addSecret(value)
+	}
+	t.Cleanup(func() { secrets = saved })
+}
+
+func TestRedact(t *testing.T) {
+
+	testSecrets(t, "s3cr3tkey", "abc")
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"secret", "Using the key s3cr3tkey", "Using the key [redacted]"},
+		{"secret in a url", "http://host/api?output=json&x=s3cr3tkey", "http://host/api?output=json&x=[redacted]"},
+		{"secret within a word", "file.xs3cr3tkey.rar", "file.xs3cr3tkey.rar"},
+		{"colored secret", "Key: \x1b[34ms3cr3tkey\x1b[0m", "Key: \x1b[34m[redacted]\x1b[0m"},
+		{"short secret in free text", "abc def", "abc def"},
+		{"short labeled password", "Password: abc", "Password: [redacted]"},
+		{"colored labeled password", "Password: \x1b[34ma b\x1b[0m", "Password: \x1b[34m[redacted]\x1b[0m"},
+		{"short secret parameter", "http://host/api?apikey=abc&mode=queue", "http://host/api?apikey=[redacted]&mode=queue"},
+		{"short secret in JSON", `{"password": "abc"}`, `{"password": "[redacted]"}`},
+		{"label within the message", "invalid otp secret: illegal base32 data", "invalid otp secret: illegal base32 data"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := redact(test.text); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+}
+
+func TestLoggerRedactsConsole(t *testing.T) {
+
+	testSecrets(t, "s3cr3tkey")
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	logger := &Logger{configured: true, level: levelOff}
+	logger.Info("Password: %s", "abc")
+	logger.Warn("Request to http://host/api?apikey=%s failed", "s3cr3tkey")
+	os.Stdout = stdout
+	writer.Close()
+	output, _ := io.ReadAll(reader)
+
+	for _, secret := range []string{"abc", "s3cr3tkey"} {
+		if strings.Contains(string(output), secret) {
+			t.Errorf("the console output %q contains the secret %q", output, secret)
+		}
+	}
+	if strings.Count(string(output), redacted) != 2 {
+		t.Errorf("got console output %q, want 2 redacted values", output)
+	}
+
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+)
+
+// routing rule structure
+// all conditions of a rule must match for the nzb file to be pushed to the targets of the rule
+type RoutingRule struct {
+	name       string
+	categories []string
+	title      *regexp.Regexp
+	group      *regexp.Regexp
+	minSize    int64
+	maxSize    int64
+	flags      []string
+	targets    []string
+}
+
+// parses a routing rule in the format "condition; condition; ... => target, target"
+// available conditions are "category:<name>,<name>", "title:<regex>", "group:<regex>", "min_size:<size>", "max_size:<size>" and "flag:<name>,<name>"
+func parseRoutingRule(name string, value string) (RoutingRule, error) {
+	rule := RoutingRule{name: name}
+	conditions, targetList, ok := strings.Cut(value, "=>")
+	if !ok {
+		return rule, fmt.Errorf("missing '=> <target>'")
+	}
+	if rule.targets = parseTargetList(targetList); len(rule.targets) == 0 {
+		return rule, fmt.Errorf("no valid target")
+	}
+	for condition := range strings.SplitSeq(conditions, ";") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" {
+			continue
+		}
+		key, argument, _ := strings.Cut(condition, ":")
+		argument = strings.TrimSpace(argument)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "category":
+			rule.categories = splitList(argument)
+		case "flag":
+			rule.flags = splitList(argument)
+		case "title", "group":
+			regex, err := regexp.Compile("(?i)" + argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid regex: %s", err.Error())
+			}
+			if strings.ToLower(strings.TrimSpace(key)) == "title" {
+				rule.title = regex
+			} else {
+				rule.group = regex
+			}
+		case "min_size", "max_size":
+			size, err := humanize.ParseBytes(argument)
+			if err != nil {
+				return rule, fmt.Errorf("invalid %s: %s", key, err.Error())
+			}
+			if strings.ToLower(strings.TrimSpace(key)) == "min_size" {
+				rule.minSize = int64(size)
+			} else {
+				rule.maxSize = int64(size)
+			}
+		default:
+			return rule, fmt.Errorf("unknown condition '%s'", key)
+		}
+	}
+	return rule, nil
+}
+
+// splits a comma separated list
+func splitList(list string) []string {
+	var items []string
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parses a comma separated list of targets and removes undefined targets
+func parseTargetList(list string) []string {
+	var result []string
+	for _, target := range splitList(list) {
+		if _, ok := targets[target]; ok {
+			result = append(result, target)
+		} else {
+			Log.Warn("Undefined target '%s'", target)
+		}
+	}
+	return result
+}
+
+// returns the explanation why the rule matched or an empty string if it did not match
+func (r *RoutingRule) match(result *Result, category string) string {
+	var reasons []string
+	if len(r.categories) > 0 {
+		if !slices.ContainsFunc(r.categories, func(c string) bool { return strings.EqualFold(c, category) }) {
+			return ""
+		}
+		reasons = append(reasons, fmt.Sprintf("category '%s'", category))
+	}
+	if r.title != nil {
+		if !r.title.MatchString(args.Title) {
+			return ""
+		}
+		reasons = append(reasons, fmt.Sprintf("title matches '%s'", strings.TrimPrefix(r.title.String(), "(?i)")))
+	}
+	if r.group != nil {
+		var groups []string
+		groups = append(groups, args.Groups...)
+		if result != nil && result.Nzb != nil {
+			for _, file := range result.Nzb.Files {
+				groups = append(groups, file.Groups...)
+			}
+		}
+		index := slices.IndexFunc(groups, r.group.MatchString)
+		if index < 0 {
+			return ""
+		}
+		reasons = append(reasons, fmt.Sprintf("group '%s'", groups[index]))
+	}
+	if r.minSize > 0 || r.maxSize > 0 {
+		var size int64
+		if result != nil && result.Nzb != nil {
+			size = result.Nzb.Bytes
+		}
+		if (r.minSize > 0 && size < r.minSize) || (r.maxSize > 0 && size > r.maxSize) {
+			return ""
+		}
+		reasons = append(reasons, fmt.Sprintf("size %s", humanize.Bytes(uint64(size))))
+	}
+	if len(r.flags) > 0 {
+		for _, flag := range r.flags {
+			if !slices.Contains(args.Flags, flag) {
+				return ""
+			}
+		}
+		reasons = append(reasons, fmt.Sprintf("flag '%s'", strings.Join(r.flags, "', '")))
+	}
+	if len(reasons) == 0 {
+		return "no conditions"
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// function to choose the targets for the nzb file based on the routing rules
+// returns the targets and the explanation which rule matched
+func routeTargets(result *Result, category string) ([]string, string) {
+
+	if len(conf.Routing) == 0 {
+		return conf.General.Targets, ""
+	}
+
+	fmt.Println()
+	Log.Info("Checking routing rules ...")
+	pushTargets, route, matched := resolveTargets(result, category)
+	switch {
+	case matched:
+		Log.Info("Routing %s matched: pushing to %s", route, strings.Join(pushTargets, ", "))
+	case route == "fallback":
+		Log.Info("No routing rule matched: pushing to fallback %s", strings.Join(pushTargets, ", "))
+	default:
+		Log.Info("No routing rule matched: pushing to %s", strings.Join(pushTargets, ", "))
+	}
+	return pushTargets, route
+
+}
+
+// returns the targets of the first matching routing rule, the explanation and true if a rule matched
+// the fallback targets or the configured targets are returned if no rule matched
+func resolveTargets(result *Result, category string) ([]string, string, bool) {
+	if len(conf.Routing) == 0 {
+		return conf.General.Targets, "", false
+	}
+	for _, rule := range conf.Routing {
+		if reason := rule.match(result, category); reason != "" {
+			return rule.targets, fmt.Sprintf("rule '%s' (%s)", rule.name, reason), true
+		}
+	}
+	if len(conf.RoutingFallback) > 0 {
+		return conf.RoutingFallback, "fallback", false
+	}
+	return conf.General.Targets, "no rule matched", false
+}
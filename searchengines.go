@@ -21,6 +21,8 @@ type SearchEngine struct {
 	groupNo     int
 	search      func(engine SearchEngine, name string) error
 	stringRegx  []RegexPattern
+	command     string // command line of a plugin
+	timeout     int    // timeout of a plugin in seconds
 }
 
 type RegexPattern struct {
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tensai75/nzbparser"
+)
+
+// spool entry structure
+// holds everything needed to retry a failed push later
+type SpoolEntry struct {
+	Header   string     `json:"header"`
+	Title    string     `json:"title"`
+	Password string     `json:"password,omitempty"`
+	Groups   []string   `json:"groups,omitempty"`
+	PostDate int64      `json:"post_date,omitempty"`
+	Engine   string     `json:"engine"`
+	Category string     `json:"category,omitempty"`
+	Options  JobOptions `json:"job_options"` // resolved job options of the failed push
+	Targets  []string   `json:"targets"`
+	Spooled  time.Time  `json:"spooled"`
+	Attempts int        `json:"attempts"`
+	Nzb      string     `json:"nzb"`
+	file     string
+}
+
+// returns the path of the spool folder which is stored next to the configuration file
+func spoolPath() string {
+	return filepath.Join(filepath.Dir(confPath), "nzb-monkey-go-spool")
+}
+
+// writes the spool entry to its file in the spool folder
+func (e *SpoolEntry) save() error {
+	if e.file == "" {
+		e.file = filepath.Join(spoolPath(), fmt.Sprintf("%d.json", e.Spooled.UnixNano()))
+	}
+	if err := os.MkdirAll(spoolPath(), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(e.file, data, 0600)
+}
+
+// loads all entries of the spool folder sorted by the time they were spooled
+func loadSpool() ([]SpoolEntry, error) {
+	var entries []SpoolEntry
+	files, err := filepath.Glob(filepath.Join(spoolPath(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var entry SpoolEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			Log.Debug("Invalid spool entry '%s': %s", file, err.Error())
+			continue
+		}
+		entry.file = file
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b SpoolEntry) int { return a.Spooled.Compare(b.Spooled) })
+	return entries, nil
+}
+
+// function to save a failed push in the spool folder so it can be retried later
+// only the targets which were unreachable are spooled, other errors (e.g. an invalid api key) will not succeed on a retry
+func spoolFailedPush(engine string, nzbfile string, category string, options JobOptions, failedTargets []string) {
+
+	if !conf.Spool.Enable || len(failedTargets) == 0 {
+		return
+	}
+
+	entry := SpoolEntry{
+		Header:   args.Header,
+		Title:    args.Title,
+		Password: args.Password,
+		Groups:   args.Groups,
+		PostDate: args.UnixDate,
+		Engine:   engine,
+		Category: category,
+		Options:  options,
+		Targets:  failedTargets,
+		Spooled:  time.Now(),
+		Nzb:      nzbfile,
+	}
+	if err := entry.save(); err != nil {
+		Log.Warn("Unable to spool the NZB file: %s", err.Error())
+		return
+	}
+	fmt.Println()
+	Log.Info("The NZB file was spooled for %s (use 'spool flush' to retry the push)", strings.Join(failedTargets, ", "))
+
+}
+
+// function to retry all spooled pushes
+// returns the number of successfully flushed entries and the number of entries
+func flushSpool(entries []SpoolEntry) (int, int) {
+
+	var flushed int
+	for _, entry := range entries {
+		if conf.Spool.MaxAge > 0 && time.Since(entry.Spooled) > time.Duration(conf.Spool.MaxAge)*24*time.Hour {
+			Log.Warn("Spooled push of '%s' is older than %d days and is deleted", entry.Title, conf.Spool.MaxAge)
+			os.Remove(entry.file)
+			continue
+		}
+		var targetNames []string
+		for _, target := range entry.Targets {
+			if _, ok := targets[target]; ok {
+				targetNames = append(targetNames, target)
+			} else {
+				Log.Warn("Undefined target '%s'", target)
+			}
+		}
+		if len(targetNames) == 0 {
+			Log.Warn("Spooled push of '%s' is kept because none of its targets is defined", entry.Title)
+			continue
+		}
+
+		// the targets use the global arguments and the pushed nzb file
+		pushedNzb = nil
+		if nzb, err := nzbparser.ParseString(entry.Nzb); err == nil {
+			pushedNzb = &Result{SearchEngine: entry.Engine, Nzb: nzb}
+		}
+		args.Header = entry.Header
+		args.Title = entry.Title
+		args.Password = entry.Password
+		args.Groups = entry.Groups
+		args.UnixDate = entry.PostDate
+		args.Category = entry.Category
+
+		fmt.Println()
+		Log.Info("Pushing spooled NZB file '%s' (spooled on %s)", blue(entry.Title), entry.Spooled.Local().Format("02.01.2006 15:04:05"))
+		pushedTargets, failedTargets, unreachableTargets, hasError := pushToTargets(targetNames, entry.Nzb, entry.Category, entry.Options)
+		if len(pushedTargets) > 0 && conf.History.Enable {
+			historyEntry := HistoryEntry{
+				Header:   entry.Header,
+				Title:    entry.Title,
+				Password: entry.Password,
+				Groups:   entry.Groups,
+				PostDate: entry.PostDate,
+				Engine:   entry.Engine,
+				Category: entry.Category,
+				Targets:  pushedTargets,
+				Route:    fmt.Sprintf("flushed from spool (spooled on %s)", entry.Spooled.Local().Format("02.01.2006 15:04:05")),
+				Added:    time.Now(),
+			}
+			if pushedNzb != nil {
+				historyEntry.Hash = nzbHash(pushedNzb.Nzb)
+			}
+			if id, err := addHistoryEntry(historyEntry); err != nil {
+				Log.Warn("Unable to write history: %s", err.Error())
+			} else if conf.History.StoreNzb {
+				storeHistoryNzb(id, entry.Nzb)
+			}
+		}
+		if !hasError {
+			if err := os.Remove(entry.file); err != nil {
+				Log.Warn("Unable to remove spooled push: %s", err.Error())
+			}
+			flushed++
+			continue
+		}
+		// targets failing with other errors than unreachable targets are not retried again
+		for _, target := range failedTargets {
+			if !slices.Contains(unreachableTargets, target) {
+				Log.Warn("Spooled push of '%s' to %s failed and is not retried", entry.Title, target)
+			}
+		}
+		if len(unreachableTargets) == 0 {
+			os.Remove(entry.file)
+			continue
+		}
+		entry.Targets = unreachableTargets
+		entry.Attempts++
+		if err := entry.save(); err != nil {
+			Log.Warn("Unable to update spooled push: %s", err.Error())
+		}
+	}
+	pushedNzb = nil
+	return flushed, len(entries)
+
+}
+
+// function to flush the spool at the start of a run
+// the global arguments are restored afterwards
+func autoFlushSpool() {
+
+	entries, err := loadSpool()
+	if err != nil {
+		Log.Warn("Unable to load spool: %s", err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	savedArgs := args
+	defer func() { args = savedArgs }()
+
+	fmt.Println()
+	Log.Info("Flushing %d spooled pushes ...", len(entries))
+	flushed, total := flushSpool(entries)
+	fmt.Println()
+	if flushed == total {
+		Log.Succ("All %d spooled pushes were flushed", total)
+	} else {
+		Log.Warn("%d of %d spooled pushes were flushed", flushed, total)
+	}
+
+}
+
+// arguments of the spool command
+type SpoolArgs struct {
+	CommonArgs
+	List  *struct{} `arg:"subcommand:list" help:"list all spooled pushes"`
+	Flush *struct{} `arg:"subcommand:flush" help:"retry all spooled pushes"`
+}
+
+var spoolArgs SpoolArgs
+
+// function to run the spool command
+func spoolCommand() {
+
+	entries, err := loadSpool()
+	if err != nil {
+		Log.Error("Unable to load spool: %s", err.Error())
+		exit(1)
+	}
+
+	fmt.Println()
+	switch {
+	case spoolArgs.List != nil:
+		if len(entries) == 0 {
+			Log.Info("No spooled pushes found")
+			return
+		}
+		Log.Info("%-17s %-9s %-15s %-20s %s", "Spooled", "Attempts", "Category", "Targets", "Title")
+		for _, entry := range entries {
+			Log.Info("%-17s %-9d %-15s %-20s %s", entry.Spooled.Local().Format("02.01.2006 15:04"), entry.Attempts, entry.Category, strings.Join(entry.Targets, ","), entry.Title)
+		}
+	case spoolArgs.Flush != nil:
+		if len(entries) == 0 {
+			Log.Info("No spooled pushes found")
+			return
+		}
+		Log.Info("Flushing %d spooled pushes ...", len(entries))
+		flushed, total := flushSpool(entries)
+		fmt.Println()
+		if flushed < total {
+			Log.Error("%d of %d spooled pushes were flushed", flushed, total)
+			exit(1)
+		}
+		Log.Succ("All %d spooled pushes were flushed", total)
+	default:
+		writeUsage(commandParser)
+		Log.Error("Missing command: list or flush")
+		exit(1)
+	}
+
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tensai75/nzbparser"
+	"github.com/nilsocket/svach"
+	progressbar "github.com/schollz/progressbar/v3"
+	"gopkg.in/ini.v1"
+)
+
+// name of the file holding the downloaded segments to resume an interrupted download
+const downloadStateFile = ".nzb-monkey-go-download.json"
+
+// returned by download_segment if the article could not be decoded
+var errArticleDamaged = errors.New("article damaged")
+
+// downloaded segments of a download folder
+type downloadState struct {
+	Done  map[string]bool             `json:"done"`  // message-ids of the downloaded segments
+	Names map[string]string           `json:"names"` // files renamed to the name of the yEnc header
+	Files map[string]downloadFileInfo `json:"files"` // yEnc data of the files to check the resumed files
+}
+
+// yEnc data of a file
+type downloadFileInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	CRC  uint32 `json:"crc"`
+}
+
+// progress of a file
+type downloadFile struct {
+	name     string // name of the file in the nzb file (key of the state)
+	path     string
+	file     *os.File
+	yencName string
+	size     int64
+	crc      uint32
+	missing  int
+	damaged  int
+}
+
+// segment to download
+type downloadJob struct {
+	file    int
+	segment nzbparser.NzbSegment
+}
+
+// target functions for the built-in downloader
+// function to create a target instance from a configuration section
+func download_newTarget(name string, section *ini.Section) (Target, error) {
+	cfg := Download{Name: name, Destination: "./Downloads/usenet"}
+	if err := section.MapTo(&cfg); err != nil {
+		return Target{}, err
+	}
+	return Target{
+		name: name,
+		push: func(nzb string, category string) error {
+			return download_push(cfg, nzb, category)
+		},
+	}, nil
+}
+
+// function to download the files of the nzb file
+func download_push(cfg Download, nzbfile string, category string) error {
+
+	fmt.Println()
+	Log.Info("Downloading the files with %s ...", cfg.Name)
+
+	if conf.Directsearch.Username == "" || conf.Directsearch.Password == "" {
+		return fmt.Errorf("no or incomplete credentials for usenet server")
+	}
+	nzb, err := nzbparser.ParseString(nzbfile)
+	if err != nil {
+		return err
+	}
+
+	// destination folder
+	sanitize, _ := svach.WithOpts("", 255)
+	path := cfg.Destination
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(homePath, path)
+	}
+	if cfg.CategoryFolder && category != "" {
+		path = filepath.Join(path, sanitize.Name(category))
+	}
+	if path, err = filepath.Abs(filepath.Join(path, sanitize.Name(args.Title))); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return err
+	}
+	Log.Info("Destination folder: %s", path)
+
+	owned, err := initNntpPool()
+	if err != nil {
+		return err
+	}
+	if owned {
+		defer closeNntpPool()
+	}
+
+	// load the state of an interrupted download
+	state := downloadState{Done: make(map[string]bool), Names: make(map[string]string), Files: make(map[string]downloadFileInfo)}
+	statePath := filepath.Join(path, downloadStateFile)
+	if data, err := os.ReadFile(statePath); err == nil {
+		if err := json.Unmarshal(data, &state); err == nil && len(state.Done) > 0 {
+			Log.Info("Resuming the download (%d segments already downloaded)", len(state.Done))
+		}
+		if state.Done == nil {
+			state.Done = make(map[string]bool)
+		}
+		if state.Names == nil {
+			state.Names = make(map[string]string)
+		}
+		if state.Files == nil {
+			state.Files = make(map[string]downloadFileInfo)
+		}
+	}
+
+	// open the files and select the segments to download
+	files := make([]*downloadFile, nzb.Files.Len())
+	var jobs []downloadJob
+	var total, done int64
+	usedNames := make(map[string]bool)
+	for i, file := range nzb.Files {
+		// files with the same name are numbered so they are not written into the same file
+		name := sanitize.Name(getFileName(file))
+		if usedNames[strings.ToLower(name)] {
+			ext := filepath.Ext(name)
+			for n := 2; usedNames[strings.ToLower(name)]; n++ {
+				name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(sanitize.Name(getFileName(file)), ext), n, ext)
+			}
+			Log.Warn("Several files are named '%s', the file is saved as '%s'", getFileName(file), name)
+		}
+		usedNames[strings.ToLower(name)] = true
+		info := state.Files[name]
+		files[i] = &downloadFile{name: name, yencName: info.Name, size: info.Size, crc: info.CRC}
+		if renamed, ok := state.Names[name]; ok {
+			name = renamed
+		}
+		files[i].path = filepath.Join(path, name)
+		if files[i].file, err = os.OpenFile(files[i].path, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+			download_closeFiles(files)
+			return err
+		}
+		files[i].missing = file.TotalSegments - file.Segments.Len()
+		for _, segment := range file.Segments {
+			total += int64(segment.Bytes)
+			if state.Done[segment.Id] {
+				done += int64(segment.Bytes)
+				continue
+			}
+			jobs = append(jobs, downloadJob{file: i, segment: segment})
+		}
+	}
+
+	// setup progress bar
+	bar := progressbar.NewOptions64(total,
+		progressbar.OptionSetDescription("   Downloading ... "),
+		progressbar.OptionSetRenderBlankState(true),
+		progressbar.OptionThrottle(time.Millisecond*100),
+		progressbar.OptionShowElapsedTimeOnFinish(),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionUseANSICodes(conf.Directsearch.UseANSICodes),
+	)
+	bar.Add64(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mutex sync.Mutex
+	var firstError error
+	saveState := func() {
+		mutex.Lock()
+		data, err := json.Marshal(state)
+		mutex.Unlock()
+		if err == nil {
+			err = os.WriteFile(statePath, data, 0644)
+		}
+		if err != nil {
+			Log.Debug("Unable to save the download state: %s", err.Error())
+		}
+	}
+
+	// save the state regularly so an interrupted download can be resumed
+	stopSaving := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopSaving:
+				return
+			case <-ticker.C:
+				saveState()
+			}
+		}
+	}()
+
+	jobChan := make(chan downloadJob)
+	var wg sync.WaitGroup
+	for range max(conf.Directsearch.Connections, 1) {
+		wg.Go(func() {
+			for job := range jobChan {
+				part, err := download_segment(ctx, job.segment.Id)
+				if err == nil {
+					_, err = files[job.file].file.WriteAt(part.Data, part.Begin-1)
+				}
+				mutex.Lock()
+				switch {
+				case errors.Is(err, errArticleNotFound):
+					Log.Debug("Article <%s> not found", job.segment.Id)
+					files[job.file].missing++
+				case errors.Is(err, errArticleDamaged):
+					Log.Debug("%s", err.Error())
+					files[job.file].damaged++
+				case err != nil:
+					if firstError == nil {
+						firstError = err
+						cancel()
+					}
+				default:
+					files[job.file].yencName = part.Name
+					files[job.file].size = part.Size
+					if part.FileCRC != 0 {
+						files[job.file].crc = part.FileCRC
+					}
+					state.Files[files[job.file].name] = downloadFileInfo{Name: part.Name, Size: part.Size, CRC: files[job.file].crc}
+					if part.CRCFailed {
+						files[job.file].damaged++
+					} else {
+						state.Done[job.segment.Id] = true
+					}
+				}
+				mutex.Unlock()
+				bar.Add(job.segment.Bytes)
+			}
+		})
+	}
+
+feed:
+	for _, job := range jobs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobChan <- job:
+		}
+	}
+	close(jobChan)
+	wg.Wait()
+	close(stopSaving)
+	bar.Finish()
+	fmt.Println()
+
+	if firstError != nil {
+		download_closeFiles(files)
+		saveState()
+		return fmt.Errorf("download aborted: %s", firstError.Error())
+	}
+
+	// check the files and report missing or damaged articles
+	// the crc32 of the whole file is also checked for resumed files as it is computed from the file on disk
+	var missing, damaged int
+	for i, file := range files {
+		if file.size > 0 {
+			file.file.Truncate(file.size)
+		}
+		if file.missing == 0 && file.damaged == 0 && file.crc != 0 {
+			file.file.Seek(0, io.SeekStart)
+			hash := crc32.NewIEEE()
+			if _, err := io.Copy(hash, file.file); err == nil && hash.Sum32() != file.crc {
+				Log.Warn("CRC32 check failed for file '%s'", filepath.Base(file.path))
+				file.damaged++
+				for _, segment := range nzb.Files[i].Segments {
+					delete(state.Done, segment.Id)
+				}
+			}
+		}
+		file.file.Close()
+		if file.missing > 0 || file.damaged > 0 {
+			Log.Warn("%d articles missing and %d articles damaged for file '%s'", file.missing, file.damaged, filepath.Base(file.path))
+			missing += file.missing
+			damaged += file.damaged
+			continue
+		}
+		// use the real filename of the yEnc header
+		if file.yencName != "" && sanitize.Name(file.yencName) != filepath.Base(file.path) {
+			newPath := filepath.Join(path, sanitize.Name(file.yencName))
+			if _, err := os.Stat(newPath); os.IsNotExist(err) {
+				if err := os.Rename(file.path, newPath); err == nil {
+					state.Names[file.name] = filepath.Base(newPath)
+					file.path = newPath
+				}
+			}
+		}
+	}
+
+	if missing > 0 || damaged > 0 {
+		saveState()
+		return fmt.Errorf("the download is incomplete: %d articles missing and %d articles damaged", missing, damaged)
+	}
+	os.Remove(statePath)
+	Log.Succ("All %d files were downloaded to '%s'", len(files), path)
+	return nil
+
+}
+
+// fetches and decodes a segment
+func download_segment(ctx context.Context, messageID string) (*yencPart, error) {
+	body, err := fetchArticle(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	part, err := decodeYenc(body, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: <%s> %s", errArticleDamaged, messageID, err.Error())
+	}
+	if part.Begin < 1 || part.End < part.Begin {
+		return nil, fmt.Errorf("%w: <%s> invalid part offsets", errArticleDamaged, messageID)
+	}
+	return part, nil
+}
+
+func download_closeFiles(files []*downloadFile) {
+	for _, file := range files {
+		if file != nil && file.file != nil {
+			file.file.Close()
+		}
+	}
+}
@@ -8,16 +8,33 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/atotto/clipboard"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/nilsocket/svach"
 	"github.com/skratchdot/open-golang/open"
+	"gopkg.in/ini.v1"
 )
 
+// function to create a target instance from a configuration section
+func execute_newTarget(name string, section *ini.Section) (Target, error) {
+	cfg := Execute{Name: name}
+	if err := section.MapTo(&cfg); err != nil {
+		return Target{}, err
+	}
+	return Target{
+		name: name,
+		push: func(nzb string, category string) error {
+			return execute_push(cfg, nzb, category)
+		},
+	}, nil
+}
+
 // function to save the nzb file
-func execute_push(nzb string, category string) error {
+func execute_push(cfg Execute, nzb string, category string) error {
 
 	fmt.Println()
 	Log.Info("Saving the NZB file ...")
@@ -26,13 +43,15 @@ func execute_push(nzb string, category string) error {
 	var path string
 	var err error
 
-	if filepath.IsAbs(conf.Execute.Nzbsavepath) {
-		basepath = conf.Execute.Nzbsavepath
+	if filepath.IsAbs(cfg.Nzbsavepath) {
+		basepath = cfg.Nzbsavepath
 	} else {
-		basepath = filepath.Join(homePath, conf.Execute.Nzbsavepath)
+		basepath = filepath.Join(homePath, cfg.Nzbsavepath)
 	}
 
-	if conf.Execute.Category_folder && category != "" {
+	if cfg.FolderTemplate != "" {
+		path = filepath.Join(basepath, expandFolderTemplate(cfg.FolderTemplate, category))
+	} else if cfg.Category_folder && category != "" {
 		path = filepath.Join(basepath, category)
 	} else {
 		path = basepath
@@ -62,35 +81,67 @@ func execute_push(nzb string, category string) error {
 	}
 
 	// clean up files before writing new one
-	if conf.Execute.CleanUpEnable {
-		execute_cleanup(basepath)
+	if cfg.CleanUpEnable {
+		execute_cleanup(cfg, basepath)
 	}
 
 	// sanitize filename
 	sanitize, _ := svach.WithOpts("", 255)
-	nzbFileName := sanitize.Name(args.Title)
-
-	// make filenames
-	zipFileName := nzbFileName
-	if conf.Execute.Passtofile && args.Password != "" {
-		// check if password contains invalid characters for file names
-		password := sanitize.Name(args.Password)
-		if password != args.Password {
-			Log.Warn("The password contains invalid characters for file names")
-		} else {
-			nzbFileName += fmt.Sprintf("{{%s}}", args.Password)
+	var nzbFileName, zipFileName string
+
+	if cfg.FilenameTemplate != "" {
+		nzbFileName = expandFilenameTemplate(cfg.FilenameTemplate, category, true)
+		// the zip file name never contains the password
+		zipFileName = expandFilenameTemplate(cfg.FilenameTemplate, category, false)
+	} else {
+		nzbFileName = sanitize.Name(args.Title)
+
+		// make filenames
+		zipFileName = nzbFileName
+		if cfg.Passtofile && args.Password != "" {
+			// check if password contains invalid characters for file names
+			password := sanitize.Name(args.Password)
+			if password != args.Password {
+				Log.Warn("The password contains invalid characters for file names")
+			} else {
+				nzbFileName += fmt.Sprintf("{{%s}}", args.Password)
+			}
+		}
+	}
+
+	// handle existing files
+	extension := ".nzb"
+	if cfg.SaveAsZip {
+		extension = ".zip"
+	}
+	baseNzbFileName, baseZipFileName := nzbFileName, zipFileName
+	for counter := 2; ; counter++ {
+		name := nzbFileName
+		if cfg.SaveAsZip {
+			name = zipFileName
+		}
+		if _, err := os.Stat(filepath.Join(path, name+extension)); err != nil {
+			break
 		}
+		if cfg.OnCollision == "skip" {
+			Log.Warn("The file '%s' already exists. Saving is skipped.", filepath.Join(path, name+extension))
+			return nil
+		} else if cfg.OnCollision != "counter" {
+			break
+		}
+		nzbFileName = addCounter(baseNzbFileName, counter)
+		zipFileName = addCounter(baseZipFileName, counter)
 	}
 
 	// write file
-	if path, err = writeFile(path, nzbFileName, nzb, conf.Execute.SaveAsZip, zipFileName); err != nil {
+	if path, err = writeFile(path, nzbFileName, nzb, cfg.SaveAsZip, zipFileName); err != nil {
 		return err
 	} else {
 		Log.Succ("The NZB file was saved as '%s'", path)
 	}
 
 	// copy password to clipboard
-	if conf.Execute.Passtoclipboard {
+	if cfg.Passtoclipboard {
 		fmt.Println()
 		Log.Info("Copying password to clipboard ...")
 		if err := clipboard.WriteAll(args.Password); err != nil {
@@ -99,7 +150,7 @@ func execute_push(nzb string, category string) error {
 	}
 
 	// execute default program
-	if !conf.Execute.Dontexecute {
+	if !cfg.Dontexecute {
 		fmt.Println()
 		Log.Info("Executing default program for NZB files ...")
 		if err := open.Run(path); err != nil {
@@ -111,6 +162,95 @@ func execute_push(nzb string, category string) error {
 
 }
 
+// adds a counter to the file name but keeps a trailing {{password}} at the end
+func addCounter(fileName string, counter int) string {
+	if strings.HasSuffix(fileName, "}}") {
+		if i := strings.LastIndex(fileName, "{{"); i >= 0 {
+			return fmt.Sprintf("%s (%d)%s", fileName[:i], counter, fileName[i:])
+		}
+	}
+	return fmt.Sprintf("%s (%d)", fileName, counter)
+}
+
+// placeholder regex for the filename and folder templates, e.g. {title} or {date:2006-01-02}
+var templateRegexp = regexp.MustCompile(`\{(\w+)(?::([^{}]*))?\}`)
+
+// empty brackets left over by empty placeholders
+var emptyBracketsRegexp = regexp.MustCompile(`\(\s*\)|\[\s*\]|\{\{\s*\}\}`)
+
+// replaces the placeholders of a template with the sanitized values
+func expandTemplate(template string, category string, withPassword bool) string {
+	sanitize, _ := svach.WithOpts("", 255)
+	result := templateRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		match := templateRegexp.FindStringSubmatch(placeholder)
+		var value string
+		switch strings.ToLower(match[1]) {
+		case "title":
+			value = args.Title
+		case "header":
+			value = args.Header
+		case "password":
+			if withPassword && args.Password != "" {
+				// check if password contains invalid characters for file names
+				if sanitize.Name(args.Password) != args.Password {
+					Log.Warn("The password contains invalid characters for file names")
+				} else {
+					value = args.Password
+				}
+			}
+		case "category":
+			value = category
+		case "engine":
+			if pushedNzb != nil {
+				value = pushedNzb.SearchEngine
+			}
+		case "size":
+			if pushedNzb != nil && pushedNzb.Nzb != nil {
+				value = humanize.Bytes(uint64(pushedNzb.Nzb.Bytes))
+			}
+		case "date", "postdate":
+			layout := match[2]
+			if layout == "" {
+				layout = "2006-01-02"
+			}
+			if strings.ToLower(match[1]) == "date" {
+				value = time.Now().Format(layout)
+			} else if args.UnixDate > 0 {
+				value = time.Unix(args.UnixDate, 0).Format(layout)
+			}
+		default:
+			Log.Warn("Unknown placeholder '%s' in template", placeholder)
+			return ""
+		}
+		if value == "" {
+			return ""
+		}
+		return sanitize.Name(value)
+	})
+	return strings.TrimSpace(emptyBracketsRegexp.ReplaceAllString(result, ""))
+}
+
+// expands the filename template
+func expandFilenameTemplate(template string, category string, withPassword bool) string {
+	sanitize, _ := svach.WithOpts("", 255)
+	fileName := sanitize.Name(expandTemplate(template, category, withPassword))
+	if fileName == "" {
+		fileName = sanitize.Name(args.Title)
+	}
+	return fileName
+}
+
+// expands the folder template where each path element is expanded separately
+func expandFolderTemplate(template string, category string) string {
+	var elements []string
+	for element := range strings.SplitSeq(filepath.ToSlash(template), "/") {
+		if element = expandTemplate(element, category, false); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return filepath.Join(elements...)
+}
+
 func writeFile(path string, fileName string, file string, compress bool, zipFileName string) (string, error) {
 	if compress {
 		path = filepath.Join(path, zipFileName+".zip")
@@ -140,24 +280,35 @@ func writeFile(path string, fileName string, file string, compress bool, zipFile
 	return path, nil
 }
 
-func execute_cleanup(path string) {
+func execute_cleanup(cfg Execute, path string) {
 	Log.Info("Cleaning up nzb folder '%s'", path)
 	if files, err := os.ReadDir(path); err == nil {
-		delete_files(files, path, 0)
+		delete_files(cfg, files, path, 0)
+	}
+}
+
+// returns the depth of the subfolders created by the category folder or the folder template
+func execute_cleanupDepth(cfg Execute) int {
+	if cfg.FolderTemplate != "" {
+		return len(strings.Split(strings.Trim(filepath.ToSlash(cfg.FolderTemplate), "/"), "/"))
+	}
+	if cfg.Category_folder {
+		return 1
 	}
+	return 0
 }
 
-func delete_files(files []fs.DirEntry, path string, level int) {
+func delete_files(cfg Execute, files []fs.DirEntry, path string, level int) {
 	for _, file := range files {
 		filePath := filepath.Join(path, file.Name())
 		if info, err := file.Info(); err == nil {
-			// if category folder is active, recursively also delete nzb files in level 1 subfolders
-			if file.IsDir() && conf.Execute.Category_folder && level < 1 {
+			// if category folder or the folder template is active, recursively also delete nzb files in the subfolders
+			if file.IsDir() && level < execute_cleanupDepth(cfg) {
 				if files, err := os.ReadDir(filePath); err == nil {
-					delete_files(files, filePath, level+1)
+					delete_files(cfg, files, filePath, level+1)
 				}
 			} else {
-				if info.Mode().IsRegular() && time.Since(info.ModTime()) > time.Hour*time.Duration(conf.Execute.CleanUpMaxAge*24) && filepath.Ext(file.Name()) == ".nzb" {
+				if info.Mode().IsRegular() && time.Since(info.ModTime()) > time.Hour*time.Duration(cfg.CleanUpMaxAge*24) && filepath.Ext(file.Name()) == ".nzb" {
 					Log.Info("Deleting file '%s'", filePath)
 					if err := os.Remove(filePath); err != nil {
 						Log.Warn("Error deleting file '%s' during cleanup: %v", filePath, err)
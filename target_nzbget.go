@@ -2,15 +2,57 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	b64 "encoding/base64"
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
 )
 
 // target functions for NZBGet
+// function to create a target instance from a configuration section
+func nzbget_newTarget(name string, section *ini.Section) (Target, error) {
+	cfg := NZBGet{Name: name, Retries: conf.General.Retries, RetryDelay: conf.General.RetryDelay, RetryPost: conf.General.RetryPost, Verify: true}
+	if err := section.MapTo(&cfg); err != nil {
+		return Target{}, err
+	}
+	if err := section.MapTo(&cfg.JobOptions); err != nil {
+		return Target{}, err
+	}
+	var nzbID int // id of the last pushed job
+	return Target{
+		name: name,
+		getCategories: func() (Categories, error) {
+			return nzbget_getCategories(cfg)
+		},
+		push: func(nzb string, category string) (err error) {
+			nzbID, err = nzbget_push(cfg, nzb, category)
+			return err
+		},
+		follow: func() error {
+			return nzbget_follow(cfg, nzbID)
+		},
+	}, nil
+}
+
+// priorities of NZBGet
+var nzbgetPriorities = map[string]int{
+	"default":   0,
+	"very_low":  -100,
+	"low":       -50,
+	"normal":    0,
+	"high":      50,
+	"very_high": 100,
+	"force":     900,
+}
+
 // function to get the categories
-func nzbget_getCategories() (Categories, error) {
+func nzbget_getCategories(cfg NZBGet) (Categories, error) {
 
 	// response structure
 	type responseStruct struct {
@@ -22,7 +64,7 @@ func nzbget_getCategories() (Categories, error) {
 
 	var categories Categories
 
-	if response, err := request(conf.Nzbget, "GET", "jsonrpc/config", nil, nil, nil, ""); err != nil {
+	if response, err := request(cfg, "GET", "jsonrpc/config", nil, nil, nil, ""); err != nil {
 		return nil, err
 	} else {
 		var jsonResponse responseStruct
@@ -44,10 +86,11 @@ func nzbget_getCategories() (Categories, error) {
 }
 
 // function to push the nzb file to the queue
-func nzbget_push(nzb string, category string) error {
+// returns the id of the job
+func nzbget_push(cfg NZBGet, nzb string, category string) (int, error) {
 
 	fmt.Println()
-	Log.Info("Pushing the NZB file to NZBGet...")
+	Log.Info("Pushing the NZB file to %s...", cfg.Name)
 
 	// response structure
 	type responseStruct struct {
@@ -55,14 +98,39 @@ func nzbget_push(nzb string, category string) error {
 	}
 
 	// if category is empty set to default category
-	if category == "" && conf.Nzbget.Category != "" {
-		category = conf.Nzbget.Category
+	if category == "" && cfg.Category != "" {
+		category = cfg.Category
 	}
 
-	// if category is provided as argument use category from arguments
-	if args.Category != "" {
-		category = args.Category
+	// set job options
+	options := cfg.JobOptions.merge(jobOptions)
+	priority := 0
+	if options.Priority != "" {
+		var err error
+		if priority, err = parsePriority(options.Priority, nzbgetPriorities); err != nil {
+			return 0, err
+		}
+	}
+	dupeScore := 0
+	if options.DupeScore != "" {
+		var err error
+		if dupeScore, err = strconv.Atoi(options.DupeScore); err != nil {
+			return 0, fmt.Errorf("invalid dupe score '%s'", options.DupeScore)
+		}
+	}
+	dupeMode := strings.ToUpper(cmp.Or(options.DupeMode, "ALL"))
+	if !slices.Contains([]string{"SCORE", "ALL", "FORCE"}, dupeMode) {
+		return 0, fmt.Errorf("unknown dupe mode '%s'", options.DupeMode)
 	}
+	ppParameters, err := parsePPParameters(options.PPParameters)
+	if err != nil {
+		return 0, err
+	}
+	// scripts are activated with their name followed by a colon as parameter
+	for _, script := range splitList(options.Script) {
+		ppParameters[script+":"] = "yes"
+	}
+	ppParameters["*unpack:password"] = args.Password // Post processing parameter: Password
 
 	// prepare body data
 	var data = map[string]interface{}{
@@ -72,36 +140,190 @@ func nzbget_push(nzb string, category string) error {
 		"params": []interface{}{
 			args.Title + ".nzb",                         // Filename
 			b64.StdEncoding.EncodeToString([]byte(nzb)), // Content (NZB File)
-			category,              // Category
-			0,                     // Priority
-			false,                 // AddToTop
-			conf.Nzbget.Addpaused, // AddPaused
-			"",                    // DupeKey
-			0,                     // DupeScore
-			"ALL",                 // DupeMode
-			map[string]interface{}{
-				"*unpack:password": args.Password, // Post processing parameter: Password
-			},
+			category,        // Category
+			priority,        // Priority
+			cfg.AddToTop,    // AddToTop
+			cfg.Addpaused,   // AddPaused
+			options.DupeKey, // DupeKey
+			dupeScore,       // DupeScore
+			dupeMode,        // DupeMode
+			ppParameters,    // Post processing parameters
 		},
 	}
 
 	if body, err := json.Marshal(data); err != nil {
-		return fmt.Errorf("cannot create body data: %v", err)
+		return 0, fmt.Errorf("cannot create body data: %v", err)
 	} else {
-		if response, err := request(conf.Nzbget, "POST", "jsonrpc", nil, nil, bytes.NewBuffer(body), ""); err != nil {
-			return err
+		if response, err := request(cfg, "POST", "jsonrpc", nil, nil, bytes.NewBuffer(body), ""); err != nil {
+			return 0, err
 		} else {
 			var jsonResponse responseStruct
 			if err := json.Unmarshal(response, &jsonResponse); err != nil {
-				return err
+				return 0, err
 			} else {
 				if jsonResponse.Result > 0 {
-					Log.Succ("The NZB file was pushed to NZBGet")
+					Log.Succ("The NZB file was pushed to %s", cfg.Name)
 				} else {
-					return fmt.Errorf("received an empty or unknown response")
+					return 0, fmt.Errorf("received an empty or unknown response")
 				}
 			}
+			if cfg.Verify {
+				nzbget_verify(cfg, jsonResponse.Result, category)
+			}
+			return jsonResponse.Result, nil
+		}
+	}
+}
+
+// group of the queue
+type nzbgetGroup struct {
+	NZBID            int    `json:"NZBID"`
+	NZBName          string `json:"NZBName"`
+	Category         string `json:"Category"`
+	Status           string `json:"Status"`
+	FileSizeMB       int    `json:"FileSizeMB"`
+	RemainingSizeMB  int    `json:"RemainingSizeMB"`
+	DownloadedSizeMB int    `json:"DownloadedSizeMB"`
+	Parameters       []struct {
+		Name  string `json:"Name"`
+		Value string `json:"Value"`
+	} `json:"Parameters"`
+}
+
+// item of the history
+type nzbgetHistoryItem struct {
+	NZBID  int    `json:"NZBID"`
+	Name   string `json:"Name"`
+	Status string `json:"Status"`
+}
+
+// function to get the group of a job from the queue
+// returns nil if the job is not in the queue
+func nzbget_group(cfg NZBGet, nzbID int) (*nzbgetGroup, error) {
+
+	// response structure
+	type responseStruct struct {
+		Result []nzbgetGroup `json:"result"`
+	}
+
+	response, err := request(cfg, "GET", "jsonrpc/listgroups", nil, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse responseStruct
+	if err := json.Unmarshal(response, &jsonResponse); err != nil {
+		return nil, err
+	}
+	for _, group := range jsonResponse.Result {
+		if group.NZBID == nzbID {
+			return &group, nil
+		}
+	}
+	return nil, nil
+}
+
+// function to get the history item of a job
+// returns nil if the job is not in the history
+func nzbget_historyItem(cfg NZBGet, nzbID int) (*nzbgetHistoryItem, error) {
+
+	// response structure
+	type responseStruct struct {
+		Result []nzbgetHistoryItem `json:"result"`
+	}
+
+	response, err := request(cfg, "GET", "jsonrpc/history", nil, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse responseStruct
+	if err := json.Unmarshal(response, &jsonResponse); err != nil {
+		return nil, err
+	}
+	for _, item := range jsonResponse.Result {
+		if item.NZBID == nzbID {
+			return &item, nil
 		}
 	}
-	return nil
+	return nil, nil
+}
+
+// function to check that the job was added to the queue with the correct name, category and password
+func nzbget_verify(cfg NZBGet, nzbID int, category string) {
+
+	group, err := nzbget_group(cfg, nzbID)
+	if err != nil {
+		Log.Warn("Unable to verify the job in the queue of %s: %s", cfg.Name, err.Error())
+		return
+	}
+	if group == nil {
+		// very small jobs may already be finished
+		if item, err := nzbget_historyItem(cfg, nzbID); err == nil && item != nil {
+			Log.Info("The job is already in the history of %s (%s)", cfg.Name, item.Status)
+			return
+		}
+		Log.Warn("The job %d was not found in the queue of %s", nzbID, cfg.Name)
+		return
+	}
+	verified := true
+	if !strings.EqualFold(strings.TrimSuffix(group.NZBName, ".nzb"), args.Title) {
+		Log.Warn("%s: the job was added with the name '%s' instead of '%s'", cfg.Name, group.NZBName, args.Title)
+		verified = false
+	}
+	if category != "" && !strings.EqualFold(group.Category, category) {
+		Log.Warn("%s: the job was added with the category '%s' instead of '%s'", cfg.Name, group.Category, category)
+		verified = false
+	}
+	password := ""
+	for _, parameter := range group.Parameters {
+		if parameter.Name == "*unpack:password" {
+			password = parameter.Value
+		}
+	}
+	if password != args.Password {
+		Log.Warn("%s: the job was added with a different password", cfg.Name)
+		verified = false
+	}
+	if verified {
+		Log.Succ("The job was verified in the queue of %s", cfg.Name)
+	}
+
+}
+
+// function to follow the job until it is completed or failed
+func nzbget_follow(cfg NZBGet, nzbID int) error {
+
+	if nzbID == 0 {
+		return fmt.Errorf("no job to follow")
+	}
+
+	return followDownload(cfg.Name, func() (string, bool, error) {
+		group, err := nzbget_group(cfg, nzbID)
+		if err != nil {
+			return "", false, err
+		}
+		if group != nil {
+			percentage := 0
+			if group.FileSizeMB > 0 {
+				percentage = (group.FileSizeMB - group.RemainingSizeMB) * 100 / group.FileSizeMB
+			}
+			return fmt.Sprintf("%s %d%% (%d MB left)", group.Status, percentage, group.RemainingSizeMB), false, nil
+		}
+		item, err := nzbget_historyItem(cfg, nzbID)
+		if err != nil {
+			return "", false, err
+		}
+		if item == nil {
+			return "", false, fmt.Errorf("the job was removed from the queue and the history")
+		}
+		status, detail, _ := strings.Cut(item.Status, "/")
+		switch status {
+		case "SUCCESS":
+			return "the download was completed", true, nil
+		case "WARNING":
+			return fmt.Sprintf("the download was completed with a warning (%s)", detail), true, nil
+		default:
+			return "", true, fmt.Errorf("the download failed (%s)", item.Status)
+		}
+	})
+
 }
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestNzbgetPriorities(t *testing.T) {
+
+	tests := []struct {
+		priority string
+		want     int
+	}{
+		{"default", 0},
+		{"very_low", -100},
+		{"low", -50},
+		{"normal", 0},
+		{"high", 50},
+		{"very_high", 100},
+		{"force", 900},
+		{"VERY_HIGH", 100},
+		{"25", 25},
+	}
+
+	for _, test := range tests {
+		t.Run(test.priority, func(t *testing.T) {
+			priority, err := parsePriority(test.priority, nzbgetPriorities)
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+			if priority != test.want {
+				t.Errorf("got priority %d, want %d", priority, test.want)
+			}
+		})
+	}
+
+	if _, err := parsePriority("urgent", nzbgetPriorities); err == nil {
+		t.Error("got no error for an unknown priority")
+	}
+
+}
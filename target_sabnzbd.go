@@ -4,11 +4,64 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
 )
 
 // target functions for SABnzbd
+// function to create a target instance from a configuration section
+func sabnzbd_newTarget(name string, section *ini.Section) (Target, error) {
+	cfg := SABnzbd{Name: name, Retries: conf.General.Retries, RetryDelay: conf.General.RetryDelay, RetryPost: conf.General.RetryPost, Verify: true}
+	if err := section.MapTo(&cfg); err != nil {
+		return Target{}, err
+	}
+	if err := section.MapTo(&cfg.JobOptions); err != nil {
+		return Target{}, err
+	}
+	var nzoID string // id of the last pushed job
+	return Target{
+		name: name,
+		getCategories: func() (Categories, error) {
+			return sabnzbd_getCategories(cfg)
+		},
+		push: func(nzb string, category string) (err error) {
+			nzoID, err = sabnzbd_push(cfg, nzb, category)
+			return err
+		},
+		follow: func() error {
+			return sabnzbd_follow(cfg, nzoID)
+		},
+	}, nil
+}
+
+// priorities of SABnzbd
+var sabnzbdPriorities = map[string]int{
+	"default":   -100,
+	"paused":    -2,
+	"very_low":  -1,
+	"low":       -1,
+	"normal":    0,
+	"high":      1,
+	"very_high": 1,
+	"force":     2,
+}
+
+// post processing levels of SABnzbd
+var sabnzbdPostProcessing = map[string]string{
+	"none":   "0",
+	"repair": "1",
+	"unpack": "2",
+	"delete": "3",
+	"0":      "0",
+	"1":      "1",
+	"2":      "2",
+	"3":      "3",
+}
+
 // function to get the categories
-func sabnzbd_getCategories() (Categories, error) {
+func sabnzbd_getCategories(cfg SABnzbd) (Categories, error) {
 
 	// response struct
 	type Response struct {
@@ -22,9 +75,9 @@ func sabnzbd_getCategories() (Categories, error) {
 	// add values
 	query.Add("mode", "get_cats")
 	query.Add("output", "json")
-	query.Add("apikey", conf.Sabnzbd.Nzbkey)
+	query.Add("apikey", cfg.Nzbkey)
 
-	if response, err := request(conf.Sabnzbd, "GET", "api", nil, query, nil, ""); err != nil {
+	if response, err := request(cfg, "GET", "api", nil, query, nil, ""); err != nil {
 		return nil, err
 	} else {
 		if err := json.Unmarshal(response, &categories); err != nil {
@@ -40,10 +93,11 @@ func sabnzbd_getCategories() (Categories, error) {
 }
 
 // function to push the nzb file to the queue
-func sabnzbd_push(nzb string, category string) error {
+// returns the id of the job
+func sabnzbd_push(cfg SABnzbd, nzb string, category string) (string, error) {
 
 	fmt.Println()
-	Log.Info("Pushing the NZB file to SABnzbd...")
+	Log.Info("Pushing the NZB file to %s...", cfg.Name)
 
 	// supported compression types
 	compressionTypes := []string{
@@ -56,20 +110,22 @@ func sabnzbd_push(nzb string, category string) error {
 		Nzo_ids []string `json:"nzo_ids"`
 	}
 
-	// if category is provided as argument use category from arguments
-	if args.Category != "" {
-		category = args.Category
-	}
-
 	// if category is empty set to default category
-	if category == "" && conf.Sabnzbd.Category != "" {
-		category = conf.Sabnzbd.Category
+	if category == "" && cfg.Category != "" {
+		category = cfg.Category
 	}
 
-	// set addPaused option
-	addPaused := "-100"
-	if conf.Sabnzbd.Addpaused {
-		addPaused = "-2"
+	// set priority and addPaused option
+	options := cfg.JobOptions.merge(jobOptions)
+	priority := -100
+	if options.Priority != "" {
+		var err error
+		if priority, err = parsePriority(options.Priority, sabnzbdPriorities); err != nil {
+			return "", err
+		}
+	}
+	if cfg.Addpaused {
+		priority = -2
 	}
 
 	// prepare query
@@ -78,31 +134,205 @@ func sabnzbd_push(nzb string, category string) error {
 	// add values
 	query.Add("mode", "addfile")
 	query.Add("output", "json")
-	query.Add("apikey", conf.Sabnzbd.Nzbkey)
+	query.Add("apikey", cfg.Nzbkey)
 	query.Add("nzbname", args.Title+".nzb")
 	query.Add("password", args.Password)
 	query.Add("cat", category)
-	query.Add("priority", addPaused)
+	query.Add("priority", strconv.Itoa(priority))
+	if options.PostProcessing != "" {
+		pp, ok := sabnzbdPostProcessing[strings.ToLower(options.PostProcessing)]
+		if !ok {
+			return "", fmt.Errorf("unknown post processing '%s'", options.PostProcessing)
+		}
+		query.Add("pp", pp)
+	}
+	if options.Script != "" {
+		query.Add("script", options.Script)
+	}
 
 	// prepare body data
-	body, contentType, err := createMultipartBody(nzb, args.Title+".nzb", conf.Sabnzbd.Compression, compressionTypes)
+	body, contentType, err := createMultipartBody(nzb, args.Title+".nzb", cfg.Compression, compressionTypes)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if response, err := request(conf.Sabnzbd, "POST", "api", nil, query, body, contentType); err != nil {
-		return err
+	if response, err := request(cfg, "POST", "api", nil, query, body, contentType); err != nil {
+		return "", err
 	} else {
 		var jsonResponse responseStruct
 		if err := json.Unmarshal(response, &jsonResponse); err != nil {
-			return err
+			return "", err
 		} else {
 			if jsonResponse.Status && len(jsonResponse.Nzo_ids) > 0 {
-				Log.Succ("The NZB file was pushed to SABnzbd")
+				Log.Succ("The NZB file was pushed to %s", cfg.Name)
 			} else {
-				return fmt.Errorf("received an empty or unknown response")
+				return "", fmt.Errorf("received an empty or unknown response")
 			}
 		}
+		if cfg.Verify {
+			sabnzbd_verify(cfg, jsonResponse.Nzo_ids[0], category)
+		}
+		return jsonResponse.Nzo_ids[0], nil
+	}
+}
+
+// queue slot of a job
+type sabnzbdQueueSlot struct {
+	NzoID      string  `json:"nzo_id"`
+	Filename   string  `json:"filename"`
+	Category   string  `json:"cat"`
+	Password   *string `json:"password"` // only returned by newer versions of SABnzbd
+	Status     string  `json:"status"`
+	Percentage string  `json:"percentage"`
+	Timeleft   string  `json:"timeleft"`
+}
+
+// history slot of a job
+type sabnzbdHistorySlot struct {
+	NzoID       string `json:"nzo_id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	FailMessage string `json:"fail_message"`
+}
+
+// function to get the queue slot of a job
+// returns nil if the job is not in the queue
+func sabnzbd_queueSlot(cfg SABnzbd, nzoID string) (*sabnzbdQueueSlot, error) {
+
+	// response structure
+	type responseStruct struct {
+		Queue struct {
+			Slots []sabnzbdQueueSlot `json:"slots"`
+		} `json:"queue"`
+	}
+
+	// prepare query
+	query := make(url.Values)
+
+	// add values
+	query.Add("mode", "queue")
+	query.Add("output", "json")
+	query.Add("apikey", cfg.Nzbkey)
+	query.Add("nzo_ids", nzoID)
+
+	response, err := request(cfg, "GET", "api", nil, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse responseStruct
+	if err := json.Unmarshal(response, &jsonResponse); err != nil {
+		return nil, err
+	}
+	for _, slot := range jsonResponse.Queue.Slots {
+		if slot.NzoID == nzoID {
+			return &slot, nil
+		}
 	}
-	return nil
+	return nil, nil
+}
+
+// function to get the history slot of a job
+// returns nil if the job is not in the history
+func sabnzbd_historySlot(cfg SABnzbd, nzoID string) (*sabnzbdHistorySlot, error) {
+
+	// response structure
+	type responseStruct struct {
+		History struct {
+			Slots []sabnzbdHistorySlot `json:"slots"`
+		} `json:"history"`
+	}
+
+	// prepare query
+	query := make(url.Values)
+
+	// add values
+	query.Add("mode", "history")
+	query.Add("output", "json")
+	query.Add("apikey", cfg.Nzbkey)
+	query.Add("nzo_ids", nzoID)
+
+	response, err := request(cfg, "GET", "api", nil, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse responseStruct
+	if err := json.Unmarshal(response, &jsonResponse); err != nil {
+		return nil, err
+	}
+	for _, slot := range jsonResponse.History.Slots {
+		if slot.NzoID == nzoID {
+			return &slot, nil
+		}
+	}
+	return nil, nil
+}
+
+// function to check that the job was added to the queue with the correct name, category and password
+func sabnzbd_verify(cfg SABnzbd, nzoID string, category string) {
+
+	slot, err := sabnzbd_queueSlot(cfg, nzoID)
+	if err != nil {
+		Log.Warn("Unable to verify the job in the queue of %s: %s", cfg.Name, err.Error())
+		return
+	}
+	if slot == nil {
+		// very small jobs may already be finished
+		if historySlot, err := sabnzbd_historySlot(cfg, nzoID); err == nil && historySlot != nil {
+			Log.Info("The job is already in the history of %s (%s)", cfg.Name, historySlot.Status)
+			return
+		}
+		Log.Warn("The job %s was not found in the queue of %s", nzoID, cfg.Name)
+		return
+	}
+	verified := true
+	if !strings.EqualFold(strings.TrimSuffix(slot.Filename, ".nzb"), args.Title) {
+		Log.Warn("%s: the job was added with the name '%s' instead of '%s'", cfg.Name, slot.Filename, args.Title)
+		verified = false
+	}
+	if category != "" && !strings.EqualFold(slot.Category, category) {
+		Log.Warn("%s: the job was added with the category '%s' instead of '%s'", cfg.Name, slot.Category, category)
+		verified = false
+	}
+	if slot.Password != nil && *slot.Password != args.Password {
+		Log.Warn("%s: the job was added with a different password", cfg.Name)
+		verified = false
+	}
+	if verified {
+		Log.Succ("The job was verified in the queue of %s", cfg.Name)
+	}
+
+}
+
+// function to follow the job until it is completed or failed
+func sabnzbd_follow(cfg SABnzbd, nzoID string) error {
+
+	if nzoID == "" {
+		return fmt.Errorf("no job to follow")
+	}
+
+	return followDownload(cfg.Name, func() (string, bool, error) {
+		slot, err := sabnzbd_queueSlot(cfg, nzoID)
+		if err != nil {
+			return "", false, err
+		}
+		if slot != nil {
+			return fmt.Sprintf("%s %s%% (%s left)", slot.Status, slot.Percentage, slot.Timeleft), false, nil
+		}
+		historySlot, err := sabnzbd_historySlot(cfg, nzoID)
+		if err != nil {
+			return "", false, err
+		}
+		if historySlot == nil {
+			return "", false, fmt.Errorf("the job was removed from the queue and the history")
+		}
+		switch historySlot.Status {
+		case "Completed":
+			return "the download was completed", true, nil
+		case "Failed":
+			return "", true, fmt.Errorf("the download failed: %s", historySlot.FailMessage)
+		default:
+			return historySlot.Status, false, nil
+		}
+	})
+
 }
@@ -2,13 +2,23 @@ package main
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
+
+	"gopkg.in/ini.v1"
 )
 
 // ds response structure
@@ -20,33 +30,132 @@ type dsResponseStruct struct {
 	} `json:"Error"`
 }
 
-// ds options structure
-type dsOptions struct {
-	sid        string
-	path       string
-	minVersion int
-	maxVersion int
+// ds api structure
+type dsAPI struct {
+	Path       string `json:"path"`
+	MinVersion int    `json:"minVersion"`
+	MaxVersion int    `json:"maxVersion"`
+}
+
+// ds error
+type dsError struct {
+	code int
+}
+
+func (e dsError) Error() string {
+	return synologyds_checkError(e.code).Error()
+}
+
+// returns true if the error is caused by an invalid or expired session
+func (e dsError) sessionError() bool {
+	return slices.Contains([]int{105, 106, 107, 119}, e.code)
 }
 
 // target functions for Synology Diskstation
+// function to create a target instance from a configuration section
+func synologyds_newTarget(name string, section *ini.Section) (Target, error) {
+	cfg := SynologyDS{Name: name, Retries: conf.General.Retries, RetryDelay: conf.General.RetryDelay, RetryPost: conf.General.RetryPost}
+	if err := section.MapTo(&cfg); err != nil {
+		return Target{}, err
+	}
+	target := Target{
+		name: name,
+		push: func(nzb string, category string) error {
+			return synologyds_push(cfg, nzb, category)
+		},
+	}
+	if cfg.UseCategory {
+		target.getCategories = func() (Categories, error) {
+			return synologyds_getCategories(cfg)
+		}
+	}
+	return target, nil
+}
+
+// function to get the shared folders as categories
+func synologyds_getCategories(cfg SynologyDS) (Categories, error) {
+
+	// response structure
+	type responseStruct struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Shares []struct {
+				Name string `json:"name"`
+			} `json:"shares"`
+		} `json:"data"`
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+
+	var categories Categories
+	err := synologyds_session(cfg, func(apis map[string]dsAPI, sid string) error {
+		api, ok := apis["SYNO.FileStation.List"]
+		if !ok {
+			return fmt.Errorf("the FileStation API is not available")
+		}
+
+		// prepare query
+		query := make(url.Values)
+
+		// add values
+		query.Add("api", "SYNO.FileStation.List")
+		query.Add("version", fmt.Sprintf("%d", min(api.MaxVersion, 2)))
+		query.Add("method", "list_share")
+		query.Add("_sid", sid)
+
+		response, err := request(cfg, "GET", "webapi/"+api.Path, nil, query, nil, "")
+		if err != nil {
+			return err
+		}
+		var jsonResponse responseStruct
+		if err := json.Unmarshal(response, &jsonResponse); err != nil {
+			return err
+		}
+		if !jsonResponse.Success {
+			return dsError{jsonResponse.Error.Code}
+		}
+		for _, share := range jsonResponse.Data.Shares {
+			categories = append(categories, share.Name)
+		}
+		return nil
+	})
+	return categories, err
+
+}
+
 // function to push the nzb file to the queue
-func synologyds_push(nzb string, category string) error {
+func synologyds_push(cfg SynologyDS, nzb string, category string) error {
 
 	fmt.Println()
-	Log.Info("Pushing the NZB file to Synology DownloadStation...")
+	Log.Info("Pushing the NZB file to %s...", cfg.Name)
 
-	if result, err := synologyds_authenticate(); err != nil {
-		return err
-	} else {
+	// the category is used as destination folder if enabled
+	destination := cfg.Destination
+	if cfg.UseCategory && category != "" {
+		destination = category
+	}
+	destination = strings.Trim(destination, " /")
+	if destination != "" {
+		Log.Info("Destination folder: %s", destination)
+	}
 
-		path := fmt.Sprintf("/%s/SYNO.DownloadStation2.Task", result.path)
+	err := synologyds_session(cfg, func(apis map[string]dsAPI, sid string) error {
+		api, ok := apis["SYNO.DownloadStation2.Task"]
+		if !ok {
+			return fmt.Errorf("the DownloadStation API is not available")
+		}
 
 		// prepare query
 		query := make(url.Values)
 
 		// add values
 		// sid is required as get parameter!
-		query.Add("_sid", result.sid)
+		query.Add("_sid", sid)
+
+		// json encoded values
+		jsonDestination, _ := json.Marshal(destination)
+		jsonPassword, _ := json.Marshal(args.Password)
 
 		// prepare body data
 		body := &bytes.Buffer{}
@@ -55,45 +164,54 @@ func synologyds_push(nzb string, category string) error {
 		// add parameters
 		writer.WriteField("api", "SYNO.DownloadStation2.Task")
 		writer.WriteField("method", "create")
-		writer.WriteField("version", fmt.Sprintf("%d", result.maxVersion))
+		writer.WriteField("version", fmt.Sprintf("%d", api.MaxVersion))
 		writer.WriteField("type", "\"file\"")
-		writer.WriteField("destination", "\"\"")
+		writer.WriteField("destination", string(jsonDestination))
 		writer.WriteField("create_list", "false")
 		writer.WriteField("mtime", fmt.Sprintf("%d", time.Now().Unix()))
 		writer.WriteField("size", fmt.Sprintf("%d", len(nzb)))
 		writer.WriteField("file", "[\"torrent\"]")
-		writer.WriteField("extract_password", fmt.Sprintf("\"%s\"", args.Password))
+		writer.WriteField("extract_password", string(jsonPassword))
 
 		// add the nzb file
 		part, _ := writer.CreateFormFile("torrent", args.Title+".nzb")
 		io.Copy(part, strings.NewReader(nzb))
 		writer.Close()
 
-		if response, err := request(conf.Synologyds, "POST", "webapi"+path, nil, query, body, writer.FormDataContentType()); err != nil {
+		response, err := request(cfg, "POST", "webapi/"+api.Path, nil, query, body, writer.FormDataContentType())
+		if err != nil {
 			return err
-		} else {
-			var jsonResponse dsResponseStruct
-			if err := json.Unmarshal(response, &jsonResponse); err != nil {
-				return err
-			} else {
-				if jsonResponse.Success {
-					Log.Succ("The NZB file was pushed to Synology DownloadStation")
-					return nil
-				} else if jsonResponse.Error.Code > 0 {
-					return synologyds_checkError(int(jsonResponse.Error.Code))
-				}
-			}
 		}
-
+		var jsonResponse dsResponseStruct
+		if err := json.Unmarshal(response, &jsonResponse); err != nil {
+			return err
+		}
+		if jsonResponse.Success {
+			return nil
+		} else if jsonResponse.Error.Code > 0 {
+			return dsError{int(jsonResponse.Error.Code)}
+		}
+		return fmt.Errorf("unknown response")
+	})
+	if err != nil {
+		return err
 	}
 
-	return fmt.Errorf("unknown response")
+	Log.Succ("The NZB file was pushed to %s", cfg.Name)
+	return nil
 }
 
-func synologyds_authenticate() (dsOptions, error) {
+// function to query the paths and versions of the required apis
+func synologyds_queryAPIs(cfg SynologyDS) (map[string]dsAPI, error) {
 
-	// return value
-	var options dsOptions
+	// response structure
+	type responseStruct struct {
+		Success bool             `json:"success"`
+		Data    map[string]dsAPI `json:"data"`
+		Error   struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
 
 	// prepare query
 	query := make(url.Values)
@@ -102,60 +220,205 @@ func synologyds_authenticate() (dsOptions, error) {
 	query.Add("api", "SYNO.API.Info")
 	query.Add("version", "1")
 	query.Add("method", "query")
-	query.Add("query", "SYNO.API.Auth,SYNO.DownloadStation2.Task")
+	query.Add("query", "SYNO.API.Auth,SYNO.DownloadStation2.Task,SYNO.FileStation.List")
+
+	response, err := request(cfg, "GET", "webapi/query.cgi", nil, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	var jsonResponse responseStruct
+	if err := json.Unmarshal(response, &jsonResponse); err != nil {
+		return nil, err
+	}
+	if !jsonResponse.Success {
+		if jsonResponse.Error.Code > 0 {
+			return nil, dsError{jsonResponse.Error.Code}
+		}
+		return nil, fmt.Errorf("unknown response while querying the APIs")
+	}
+	if _, ok := jsonResponse.Data["SYNO.API.Auth"]; !ok {
+		return nil, fmt.Errorf("the authentication API is not available")
+	}
+	return jsonResponse.Data, nil
+}
+
+// function to run the api call with a session
+// a cached session is reused and replaced by a new session if it has expired
+func synologyds_session(cfg SynologyDS, call func(map[string]dsAPI, string) error) error {
+
+	apis, err := synologyds_queryAPIs(cfg)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s@%s:%d", cfg.Username, cfg.Host, cfg.Port)
+	sid, cached := synologyds_cachedSid(key)
+	if !cached {
+		if sid, err = synologyds_authenticate(cfg, apis["SYNO.API.Auth"]); err != nil {
+			return err
+		}
+	}
 
-	if response, err := request(conf.Synologyds, "GET", "webapi/query.cgi", nil, query, nil, ""); err != nil {
-		return options, err
+	err = call(apis, sid)
+	var dsErr dsError
+	if cached && errors.As(err, &dsErr) && dsErr.sessionError() {
+		Log.Debug("The cached session of %s has expired", cfg.Name)
+		if sid, err = synologyds_authenticate(cfg, apis["SYNO.API.Auth"]); err != nil {
+			synologyds_cacheSid(key, "")
+			return err
+		}
+		err = call(apis, sid)
+	}
+
+	if cfg.Logout {
+		synologyds_logout(cfg, apis["SYNO.API.Auth"], sid)
+		synologyds_cacheSid(key, "")
 	} else {
-		var jsonResponse dsResponseStruct
+		synologyds_cacheSid(key, sid)
+	}
+	return err
+
+}
+
+// function to log in and get a new session id
+// a 2-factor authentication code is created from the otp secret or requested from the user if required
+func synologyds_authenticate(cfg SynologyDS, api dsAPI) (string, error) {
+
+	// response structure
+	type responseStruct struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Sid string `json:"sid"`
+		} `json:"data"`
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+
+	otpCode := ""
+	if cfg.OtpSecret != "" {
+		var err error
+		if otpCode, err = totp(cfg.OtpSecret, time.Now()); err != nil {
+			return "", fmt.Errorf("invalid otp secret: %s", err.Error())
+		}
+	}
+
+	for {
+		// prepare query
+		query := make(url.Values)
+
+		// add values
+		query.Add("api", "SYNO.API.Auth")
+		query.Add("version", fmt.Sprintf("%d", api.MaxVersion))
+		query.Add("method", "login")
+		query.Add("account", cfg.Username)
+		query.Add("passwd", cfg.Password)
+		query.Add("session", "DownloadStation")
+		query.Add("format", "sid")
+		if otpCode != "" {
+			query.Add("otp_code", otpCode)
+		}
+
+		response, err := request(cfg, "GET", "webapi/"+api.Path, nil, query, nil, "")
+		if err != nil {
+			return "", err
+		}
+		var jsonResponse responseStruct
 		if err := json.Unmarshal(response, &jsonResponse); err != nil {
-			return options, err
-		} else {
-			if jsonResponse.Success {
-				if _, ok := jsonResponse.Data["SYNO.DownloadStation2.Task"]; ok {
-					options.path = jsonResponse.Data["SYNO.DownloadStation2.Task"].(map[string]interface{})["path"].(string)
-					options.minVersion = int(jsonResponse.Data["SYNO.DownloadStation2.Task"].(map[string]interface{})["minVersion"].(float64))
-					options.maxVersion = int(jsonResponse.Data["SYNO.DownloadStation2.Task"].(map[string]interface{})["maxVersion"].(float64))
-					if _, ok := jsonResponse.Data["SYNO.API.Auth"]; ok {
-						// set path
-						path := jsonResponse.Data["SYNO.API.Auth"].(map[string]interface{})["path"].(string)
-
-						// prepare query
-						query := make(url.Values)
-
-						// add values
-						query.Add("api", "SYNO.API.Auth")
-						query.Add("version", fmt.Sprintf("%d", int(jsonResponse.Data["SYNO.API.Auth"].(map[string]interface{})["maxVersion"].(float64))))
-						query.Add("method", "login")
-						query.Add("account", conf.Synologyds.Username)
-						query.Add("passwd", conf.Synologyds.Password)
-						query.Add("session", "DownloadStation")
-						query.Add("format", "sid")
-
-						if response, err := request(conf.Synologyds, "GET", "webapi/"+path, nil, query, nil, ""); err != nil {
-							return options, err
-						} else {
-							var jsonResponse dsResponseStruct
-							if err := json.Unmarshal(response, &jsonResponse); err != nil {
-								return options, err
-							} else {
-								if jsonResponse.Success {
-									if _, ok := jsonResponse.Data["sid"]; ok {
-										options.sid = jsonResponse.Data["sid"].(string)
-										return options, nil
-									}
-								}
-							}
-						}
-					}
-				}
-			} else if jsonResponse.Error.Code > 0 {
-				return options, synologyds_checkError(int(jsonResponse.Error.Code))
+			return "", err
+		}
+		if jsonResponse.Success && jsonResponse.Data.Sid != "" {
+			addSecret(jsonResponse.Data.Sid)
+			return jsonResponse.Data.Sid, nil
+		}
+		if jsonResponse.Error.Code == 0 {
+			return "", fmt.Errorf("unknown response while authenticating")
+		}
+		// 2-factor authentication code required
+		if (jsonResponse.Error.Code == 403 || jsonResponse.Error.Code == 406) && otpCode == "" {
+			fmt.Print("   Enter the 2-factor authentication code: ")
+			if otpCode = inputReader(); otpCode != "" {
+				continue
 			}
 		}
+		return "", dsError{jsonResponse.Error.Code}
+	}
+
+}
+
+// function to log out of the session
+func synologyds_logout(cfg SynologyDS, api dsAPI, sid string) {
+
+	// prepare query
+	query := make(url.Values)
+
+	// add values
+	query.Add("api", "SYNO.API.Auth")
+	query.Add("version", fmt.Sprintf("%d", api.MaxVersion))
+	query.Add("method", "logout")
+	query.Add("session", "DownloadStation")
+	query.Add("_sid", sid)
+
+	if _, err := request(cfg, "GET", "webapi/"+api.Path, nil, query, nil, ""); err != nil {
+		Log.Debug("Unable to log out of %s: %s", cfg.Name, err.Error())
+	}
+
+}
+
+// returns the path of the session cache which is stored next to the configuration file
+func synologyds_sidCachePath() string {
+	return filepath.Join(filepath.Dir(confPath), "nzb-monkey-go-synology.json")
+}
+
+// returns the cached session id for the account
+func synologyds_cachedSid(key string) (string, bool) {
+	var cache map[string]string
+	if data, err := os.ReadFile(synologyds_sidCachePath()); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	sid, ok := cache[key]
+	addSecret(sid)
+	return sid, ok && sid != ""
+}
+
+// stores the session id for the account in the cache (an empty session id removes the account)
+func synologyds_cacheSid(key string, sid string) {
+	cache := make(map[string]string)
+	if data, err := os.ReadFile(synologyds_sidCachePath()); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	if sid == "" {
+		if _, ok := cache[key]; !ok {
+			return
+		}
+		delete(cache, key)
+	} else {
+		cache[key] = sid
+	}
+	data, err := json.Marshal(cache)
+	if err == nil {
+		err = os.WriteFile(synologyds_sidCachePath(), data, 0600)
 	}
+	if err != nil {
+		Log.Debug("Unable to write the session cache: %s", err.Error())
+	}
+}
 
-	return options, fmt.Errorf("unknown response while authenticating")
+// returns the time-based one-time password (RFC 6238) for the base32 encoded secret
+func totp(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", err
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(t.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	hash := mac.Sum(nil)
+	offset := hash[len(hash)-1] & 0x0f
+	code := binary.BigEndian.Uint32(hash[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1000000), nil
 }
 
 func synologyds_checkError(errorCode int) error {
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTotp(t *testing.T) {
+
+	// test vectors of RFC 6238 (SHA1) reduced to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // base32 of "12345678901234567890"
+	tests := []struct {
+		name   string
+		secret string
+		time   int64
+		want   string
+	}{
+		{"59", secret, 59, "287082"},
+		{"1111111109", secret, 1111111109, "081804"},
+		{"1111111111", secret, 1111111111, "050471"},
+		{"1234567890", secret, 1234567890, "005924"},
+		{"2000000000", secret, 2000000000, "279037"},
+		{"20000000000", secret, 20000000000, "353130"},
+		{"lower case with spaces", "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", 59, "287082"},
+		{"padding", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ====", 59, "287082"},
+		{"invalid secret", "GEZDGNBV1", 59, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := totp(test.secret, time.Unix(test.time, 0))
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("got code %s, want error", code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+			if code != test.want {
+				t.Errorf("got code %s, want %s", code, test.want)
+			}
+		})
+	}
+
+}
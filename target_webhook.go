@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/ini.v1"
+)
+
+// data passed to the body template of the webhook
+type webhookData struct {
+	Title     string   `json:"title"`
+	Header    string   `json:"header"`
+	Password  string   `json:"password"`
+	Category  string   `json:"category"`
+	Groups    []string `json:"groups"`
+	Engine    string   `json:"engine"`
+	Filename  string   `json:"filename"`
+	Nzb       string   `json:"-"`
+	NzbBase64 string   `json:"nzb"`
+}
+
+// target functions for webhooks
+// function to create a target instance from a configuration section
+func webhook_newTarget(name string, section *ini.Section) (Target, error) {
+	cfg := Webhook{
+		Name:          name,
+		Method:        "POST",
+		Body:          "json",
+		FileField:     "nzbfile",
+		SuccessStatus: "200,201,202,204",
+		Retries:       conf.General.Retries,
+		RetryDelay:    conf.General.RetryDelay,
+		RetryPost:     conf.General.RetryPost,
+	}
+	if err := section.MapTo(&cfg); err != nil {
+		return Target{}, err
+	}
+	if cfg.URL == "" {
+		return Target{}, fmt.Errorf("no url defined")
+	}
+	parsedURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid url: %s", err.Error())
+	}
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return Target{}, fmt.Errorf("invalid url: only http and https urls are supported")
+	}
+	query := parsedURL.Query()
+
+	cfg.Headers = make(map[string]string)
+	for _, key := range section.Keys() {
+		if header, ok := strings.CutPrefix(key.Name(), "header."); ok && header != "" {
+			cfg.Headers[header] = key.Value()
+		}
+	}
+	if cfg.BearerToken != "" {
+		cfg.Headers["Authorization"] = "Bearer " + cfg.BearerToken
+	}
+
+	cfg.Body = strings.ToLower(cfg.Body)
+	var bodyTemplate *template.Template
+	switch cfg.Body {
+	case "json", "multipart":
+	case "template":
+		text := cfg.Template
+		if cfg.TemplateFile != "" {
+			data, err := os.ReadFile(cfg.TemplateFile)
+			if err != nil {
+				return Target{}, fmt.Errorf("unable to read template file: %s", err.Error())
+			}
+			text = string(data)
+		}
+		if bodyTemplate, err = template.New(name).Funcs(webhookTemplateFuncs).Parse(text); err != nil {
+			return Target{}, fmt.Errorf("invalid template: %s", err.Error())
+		}
+	default:
+		return Target{}, fmt.Errorf("unknown body type '%s'", cfg.Body)
+	}
+
+	var successStatus []int
+	for _, status := range splitList(cfg.SuccessStatus) {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return Target{}, fmt.Errorf("invalid success_status '%s'", status)
+		}
+		successStatus = append(successStatus, code)
+	}
+
+	return Target{
+		name: name,
+		push: func(nzb string, category string) error {
+			return webhook_push(cfg, query, bodyTemplate, successStatus, nzb, category)
+		},
+	}, nil
+}
+
+// functions available in the body template
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"base64": func(value string) string {
+		return b64.StdEncoding.EncodeToString([]byte(value))
+	},
+}
+
+// function to send the nzb file to the webhook
+func webhook_push(cfg Webhook, query url.Values, bodyTemplate *template.Template, successStatus []int, nzb string, category string) error {
+
+	fmt.Println()
+	Log.Info("Pushing the NZB file to %s...", cfg.Name)
+
+	data := webhookData{
+		Title:     args.Title,
+		Header:    args.Header,
+		Password:  args.Password,
+		Category:  category,
+		Groups:    args.Groups,
+		Filename:  args.Title + ".nzb",
+		Nzb:       nzb,
+		NzbBase64: b64.StdEncoding.EncodeToString([]byte(nzb)),
+	}
+	if pushedNzb != nil {
+		data.Engine = pushedNzb.SearchEngine
+	}
+
+	// prepare body data
+	body := &bytes.Buffer{}
+	contentType := cfg.ContentType
+	switch cfg.Body {
+	case "json":
+		if err := json.NewEncoder(body).Encode(data); err != nil {
+			return fmt.Errorf("cannot create body data: %v", err)
+		}
+		contentType = "application/json"
+	case "multipart":
+		writer := multipart.NewWriter(body)
+		writer.WriteField("title", data.Title)
+		writer.WriteField("header", data.Header)
+		writer.WriteField("password", data.Password)
+		writer.WriteField("category", data.Category)
+		writer.WriteField("groups", strings.Join(data.Groups, ","))
+		part, err := writer.CreateFormFile(cfg.FileField, data.Filename)
+		if err != nil {
+			return err
+		}
+		part.Write([]byte(nzb))
+		writer.Close()
+		contentType = writer.FormDataContentType()
+	case "template":
+		if err := bodyTemplate.Execute(body, data); err != nil {
+			return fmt.Errorf("cannot create body data: %v", err)
+		}
+	}
+
+	response, err := request(cfg, strings.ToUpper(cfg.Method), "", cfg.Headers, query, body, contentType)
+	if err != nil {
+		var statusError *httpStatusError
+		if !errors.As(err, &statusError) || !slices.Contains(successStatus, statusError.statusCode) {
+			return err
+		}
+		response = statusError.body
+	} else if !slices.Contains(successStatus, 200) {
+		return fmt.Errorf("unexpected response status 200 OK")
+	}
+
+	if cfg.SuccessJSON != "" {
+		path, expected, _ := strings.Cut(cfg.SuccessJSON, "=")
+		path, expected = strings.TrimSpace(path), strings.TrimSpace(expected)
+		var jsonResponse any
+		if err := json.Unmarshal(response, &jsonResponse); err != nil {
+			return fmt.Errorf("invalid JSON response: %s", err.Error())
+		}
+		value, ok := jsonPathValue(jsonResponse, path)
+		if !ok {
+			return fmt.Errorf("'%s' is missing in the response", path)
+		}
+		if fmt.Sprint(value) != expected {
+			return fmt.Errorf("'%s' is '%v' instead of '%s'", path, value, expected)
+		}
+	}
+
+	Log.Succ("The NZB file was pushed to %s", cfg.Name)
+	return nil
+}
+
+// returns the value at the dot separated path of a decoded JSON value, e.g. "result.items.0.id"
+func jsonPathValue(value any, path string) (any, bool) {
+	if path == "" {
+		return value, true
+	}
+	for element := range strings.SplitSeq(path, ".") {
+		switch typed := value.(type) {
+		case map[string]any:
+			var ok bool
+			if value, ok = typed[element]; !ok {
+				return nil, false
+			}
+		case []any:
+			index, err := strconv.Atoi(element)
+			if err != nil || index < 0 || index >= len(typed) {
+				return nil, false
+			}
+			value = typed[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -7,8 +7,10 @@ import (
 	"io"
 	"mime/multipart"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/slices"
+	"gopkg.in/ini.v1"
 )
 
 // nzb file target structure
@@ -16,31 +18,195 @@ type Target struct {
 	name          string
 	getCategories func() (Categories, error)
 	push          func(string, string) error
+	follow        func() error // nil if the target does not support following the download
+	categoryMap   map[string]string
 }
 
-// nzb file targets map
+// returns the category of the target for a category of the monkey
+func (t Target) mapCategory(category string) string {
+	if mapped, ok := t.categoryMap[strings.ToLower(category)]; ok {
+		return mapped
+	}
+	return category
+}
+
+// nzb file targets map (key is the name of the target instance)
 type Targets map[string]Target
 
 // global nzb files targets map
-var targets = Targets{
-	"NZBGET": Target{
-		name:          "NZBGet",
-		getCategories: nzbget_getCategories,
-		push:          nzbget_push,
+// will hold the target instances defined in the configuration file
+var targets = make(Targets)
+
+// nzb file target type structure
+type TargetType struct {
+	name      string
+	newTarget func(string, *ini.Section) (Target, error)
+}
+
+// nzb file target types map
+type TargetTypes map[string]TargetType
+
+// global nzb file target types map
+// the key is used for the "type" of a [TARGET:<name>] section and as the name of the default section
+var targetTypes = TargetTypes{
+	"NZBGET": TargetType{
+		name:      "NZBGet",
+		newTarget: nzbget_newTarget,
+	},
+	"SABNZBD": TargetType{
+		name:      "SABnzbd",
+		newTarget: sabnzbd_newTarget,
 	},
-	"SABNZBD": Target{
-		name:          "SABnzbd",
-		getCategories: sabnzbd_getCategories,
-		push:          sabnzbd_push,
+	"SYNOLOGYDLS": TargetType{
+		name:      "Synology DownloadStation",
+		newTarget: synologyds_newTarget,
 	},
-	"SYNOLOGYDLS": Target{
-		name: "Synology DownloadStation",
-		push: synologyds_push,
+	"WEBHOOK": TargetType{
+		name:      "Webhook",
+		newTarget: webhook_newTarget,
 	},
-	"EXECUTE": Target{
-		name: "Download folder",
-		push: execute_push,
+	"DOWNLOAD": TargetType{
+		name:      "Downloader",
+		newTarget: download_newTarget,
 	},
+	"EXECUTE": TargetType{
+		name:      "Download folder",
+		newTarget: execute_newTarget,
+	},
+}
+
+// function to load the target instances from the configuration file
+// the sections [SABNZBD], [NZBGET], [SYNOLOGYDLS] and [EXECUTE] define the default instances named after their type
+// additional instances are defined in sections [TARGET:<name>] with a "type" key
+func loadTargets(cfg *ini.File) {
+	for _, section := range cfg.Sections() {
+		var instance, targetType string
+		if _, ok := targetTypes[section.Name()]; ok {
+			instance, targetType = section.Name(), section.Name()
+		} else if name, ok := strings.CutPrefix(section.Name(), "TARGET:"); ok && name != "" {
+			instance, targetType = name, strings.ToUpper(strings.TrimSpace(section.Key("type").String()))
+		} else {
+			continue
+		}
+		if _, ok := targetTypes[targetType]; !ok {
+			Log.Warn("Unknown type '%s' for target '%s'", targetType, instance)
+			continue
+		}
+		if _, ok := targets[instance]; ok {
+			Log.Warn("Target '%s' is defined more than once", instance)
+			continue
+		}
+		name := targetTypes[targetType].name
+		if instance != targetType {
+			name = fmt.Sprintf("%s (%s)", name, instance)
+		}
+		target, err := targetTypes[targetType].newTarget(name, section)
+		if err != nil {
+			Log.Warn("Unable to load target '%s': %s", instance, err.Error())
+			continue
+		}
+		if target.categoryMap, err = parseCategoryMap(section.Key("category_map").String()); err != nil {
+			Log.Warn("Invalid category_map for target '%s': %s", instance, err.Error())
+		}
+		targets[instance] = target
+	}
+	// the EXECUTE target does not require any settings
+	if _, ok := targets["EXECUTE"]; !ok {
+		if target, err := execute_newTarget(targetTypes["EXECUTE"].name, cfg.Section("EXECUTE")); err == nil {
+			targets["EXECUTE"] = target
+		}
+	}
+}
+
+// function to push the nzb file to the targets according to the target mode
+// all: push to all targets, every error is an error
+// failover: push to the targets in the given order and stop at the first successful push
+// first_success: push to all targets, errors are only an error if no push was successful
+// returns the targets the nzb file was pushed to, the targets the push failed for,
+// the failed targets which were unreachable (and can be retried later) and true if the push failed
+func pushToTargets(targetNames []string, nzbfile string, category string, options JobOptions) ([]string, []string, []string, bool) {
+	var pushedTargets []string
+	var failedTargets []string
+	var unreachableTargets []string
+	jobOptions = options
+	for i, target := range targetNames {
+		targetCategory := targets[target].mapCategory(category)
+		if selected, ok := manualCategories[target]; ok {
+			targetCategory = selected
+		}
+		if err := targets[target].push(nzbfile, targetCategory); err != nil {
+			if conf.General.TargetMode != "all" && i < len(targetNames)-1 {
+				Log.Warn("%s: %s", targets[target].name, err.Error())
+			} else {
+				Log.Error("%s: %s", targets[target].name, err.Error())
+			}
+			failedTargets = append(failedTargets, target)
+			if isTransientError(err) {
+				unreachableTargets = append(unreachableTargets, target)
+			}
+		} else {
+			pushedTargets = append(pushedTargets, target)
+			if conf.General.TargetMode == "failover" {
+				break
+			}
+		}
+	}
+	if conf.General.TargetMode == "all" {
+		return pushedTargets, failedTargets, unreachableTargets, len(failedTargets) > 0
+	}
+	return pushedTargets, failedTargets, unreachableTargets, len(pushedTargets) == 0
+}
+
+// function to follow the downloads of the pushed nzb file until they are completed or failed
+// returns true if a download failed
+func followTargets(targetNames []string) bool {
+	var hasError bool
+	for _, target := range targetNames {
+		if targets[target].follow == nil {
+			Log.Warn("%s: following the download is not supported", targets[target].name)
+			continue
+		}
+		if err := targets[target].follow(); err != nil {
+			Log.Error("%s: %s", targets[target].name, err.Error())
+			hasError = true
+		}
+	}
+	return hasError
+}
+
+// polls the status of a download until it is completed or failed or the follow timeout is reached
+// poll returns the current status, true if the download is finished and an error if the download failed
+// transient errors of the status requests are only logged and the status is requested again
+func followDownload(name string, poll func() (string, bool, error)) error {
+	fmt.Println()
+	Log.Info("Following the download on %s ...", name)
+	interval := time.Duration(max(conf.General.FollowInterval, 1)) * time.Second
+	timeout := time.Duration(conf.General.FollowTimeout) * time.Minute
+	started := time.Now()
+	lastStatus := ""
+	for {
+		status, done, err := poll()
+		fmt.Print("\033[G\033[K") // move the cursor left and clear the line
+		switch {
+		case err != nil && !isTransientError(err):
+			return err
+		case err != nil:
+			Log.Warn("%s: unable to get the status: %s", name, err.Error())
+			status = lastStatus
+		case done:
+			Log.Succ("%s: %s", name, status)
+			return nil
+		}
+		if timeout > 0 && time.Since(started) >= timeout {
+			return fmt.Errorf("the download was not finished after %s", timeout)
+		}
+		if status != lastStatus {
+			Log.Debug("%s: %s", name, status)
+			lastStatus = status
+		}
+		fmt.Printf("   %s: %s", name, status)
+		time.Sleep(interval)
+	}
 }
 
 func createMultipartBody(nzb string, filename string, compression string, compressionTypes []string) (*bytes.Buffer, string, error) {
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recorded HTTP request and response
+type httpRecord struct {
+	Sequence        int               `json:"sequence"`
+	Time            string            `json:"time"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	Status          int               `json:"status,omitempty"`
+	StatusText      string            `json:"status_text,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	Base64          bool              `json:"response_body_base64,omitempty"` // true if the response body is binary
+	Error           string            `json:"error,omitempty"`
+}
+
+// headers with secret values which are recorded as [redacted]
+var secretHeaders = []string{"authorization", "cookie", "set-cookie", "x-api-key", "x-gotify-key"}
+
+// characters not allowed in the names of the trace files
+var traceNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+var (
+	traceMutex    sync.Mutex
+	traceSequence int
+	replayRecords map[string][]httpRecord // recorded responses per method and url in the order of the recording
+)
+
+// function to check the trace and replay arguments
+func initHTTPTrace() {
+	if args.TraceHTTP != "" && args.Replay != "" {
+		Log.Error("--trace-http and --replay cannot be used together")
+		exit(1)
+	}
+	if args.TraceHTTP != "" {
+		if err := os.MkdirAll(args.TraceHTTP, os.ModePerm); err != nil {
+			Log.Error("Unable to create the trace folder '%s': %s", args.TraceHTTP, err.Error())
+			exit(1)
+		}
+		Log.Info("Recording the HTTP requests to '%s'", args.TraceHTTP)
+	}
+	if args.Replay != "" {
+		if err := loadReplay(args.Replay); err != nil {
+			Log.Error("Unable to load the recorded HTTP requests: %s", err.Error())
+			exit(1)
+		}
+		Log.Info("Replaying the HTTP requests recorded in '%s'", args.Replay)
+	}
+}
+
+// returns the key of a request to find the recorded response
+// the url is redacted as the recorded urls are redacted
+func replayKey(method string, rawURL string) string {
+	return method + " " + redact(normalizeURL(rawURL))
+}
+
+// function to load the recorded requests of the folder
+func loadReplay(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no recorded requests found in '%s'", dir)
+	}
+	var records []httpRecord
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var record httpRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("invalid recording '%s': %s", filepath.Base(file), err.Error())
+		}
+		records = append(records, record)
+	}
+	slices.SortStableFunc(records, func(a, b httpRecord) int { return a.Sequence - b.Sequence })
+	replayRecords = make(map[string][]httpRecord)
+	for _, record := range records {
+		key := record.Method + " " + record.URL
+		replayRecords[key] = append(replayRecords[key], record)
+	}
+	return nil
+}
+
+// returns the recorded response of the request
+// identical requests get the recorded responses in the order of the recording, the last one is repeated
+func replayRequest(method string, rawURL string) ([]byte, error) {
+	traceMutex.Lock()
+	defer traceMutex.Unlock()
+	key := replayKey(method, rawURL)
+	records := replayRecords[key]
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	record := records[0]
+	if len(records) > 1 {
+		replayRecords[key] = records[1:]
+	}
+	Log.Debug("Replaying the response of recording %d for %s", record.Sequence, key)
+
+	if record.Error != "" {
+		return nil, errors.New(record.Error)
+	}
+	body := []byte(record.ResponseBody)
+	if record.Base64 {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(record.ResponseBody); err != nil {
+			return nil, fmt.Errorf("invalid recording %d: %s", record.Sequence, err.Error())
+		}
+	}
+	if record.Status != http.StatusOK {
+		return nil, &httpStatusError{statusCode: record.Status, status: record.StatusText, body: body}
+	}
+	return body, nil
+}
+
+// function to record the request and the response
+// secrets are redacted and the headers with secrets are not recorded
+func traceRequest(req *http.Request, requestBody []byte, resp *http.Response, responseBody []byte, requestError error) {
+
+	traceMutex.Lock()
+	traceSequence++
+	sequence := traceSequence
+	traceMutex.Unlock()
+
+	record := httpRecord{
+		Sequence:       sequence,
+		Time:           time.Now().Format(time.RFC3339),
+		Method:         req.Method,
+		URL:            redact(normalizeURL(req.URL.String())),
+		RequestHeaders: traceHeaders(req.Header),
+		RequestBody:    redact(string(requestBody)),
+	}
+	if !utf8.Valid(requestBody) {
+		record.RequestBody = fmt.Sprintf("[%d bytes of binary data]", len(requestBody))
+	}
+	if requestError != nil {
+		record.Error = redact(requestError.Error())
+	}
+	if resp != nil {
+		record.Status = resp.StatusCode
+		record.StatusText = resp.Status
+		record.ResponseHeaders = traceHeaders(resp.Header)
+		if utf8.Valid(responseBody) {
+			record.ResponseBody = redact(string(responseBody))
+		} else {
+			record.ResponseBody, record.Base64 = base64.StdEncoding.EncodeToString(responseBody), true
+		}
+	}
+
+	// the urls stay readable without escaping of &, < and >
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(record)
+	if err == nil {
+		name := fmt.Sprintf("%04d-%s-%s.json", sequence, req.Method, traceNameRegexp.ReplaceAllString(req.URL.Host, "_"))
+		err = os.WriteFile(filepath.Join(args.TraceHTTP, name), data.Bytes(), 0644)
+	}
+	if err != nil {
+		Log.Warn("Unable to record the HTTP request: %s", err.Error())
+	}
+
+}
+
+// returns the headers with the values of the headers with secrets redacted
+func traceHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for name, values := range header {
+		if slices.Contains(secretHeaders, strings.ToLower(name)) {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = redact(strings.Join(values, ", "))
+	}
+	return headers
+}
+
+// returns the body as bytes and a new reader of the body to record the body of the request
+func bufferBody(body io.Reader) ([]byte, io.Reader, error) {
+	if body == nil {
+		return nil, nil, nil
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, bytes.NewReader(data), nil
+}
+
+// returns the url with the query parameters in a stable order
+// used to match the requests with the recordings
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
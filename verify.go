@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Tensai75/nntp"
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+// availability of the articles of a file
+type fileAvailability struct {
+	checked     int
+	unavailable int
+}
+
+// article to check on the news server
+type statJob struct {
+	file      int
+	messageID string
+}
+
+// function to verify the availability of the articles of a result on the news server
+// the missing segments and files of the result are updated with the verified availability
+func verifyResult(result *Result) {
+
+	fmt.Println()
+	if conf.Directsearch.Username == "" || conf.Directsearch.Password == "" {
+		Log.Info("Skipping the verification of the article availability: no or incomplete credentials for usenet server")
+		return
+	}
+	Log.Info("Verifying article availability on %s ...", conf.Directsearch.Host)
+	owned, err := initNntpPool()
+	if err != nil {
+		Log.Warn("Unable to verify article availability: %s", err.Error())
+		return
+	}
+	if owned {
+		defer closeNntpPool()
+	}
+
+	availability, err := checkAvailability(result)
+	if err != nil {
+		Log.Warn("Unable to verify article availability: %s", err.Error())
+		return
+	}
+
+	// report the availability per file and extrapolate the unavailable segments
+	// the segments of a missing file are not added to the missing segments as the file is already counted
+	var checked, unavailable, estimated, missingFiles, missingFileSegments int
+	result.FilesUnavailable = make([]int, result.Nzb.Files.Len())
+	for i, file := range result.Nzb.Files {
+		if availability[i].checked == 0 {
+			continue
+		}
+		checked += availability[i].checked
+		unavailable += availability[i].unavailable
+		if availability[i].unavailable > 0 {
+			Log.Warn("%d of %d articles unavailable for file '%s'", availability[i].unavailable, availability[i].checked, file.Subject)
+			result.FilesUnavailable[i] = int(math.Round(float64(availability[i].unavailable) / float64(availability[i].checked) * float64(file.Segments.Len())))
+			estimated += result.FilesUnavailable[i]
+			// a file is only missing if all of its articles were checked, with sampling it is an estimate
+			if availability[i].unavailable == availability[i].checked {
+				if availability[i].checked == file.Segments.Len() {
+					missingFiles++
+					missingFileSegments += result.FilesUnavailable[i]
+				} else {
+					Log.Warn("File '%s' is probably missing (none of the %d sampled articles is available)", file.Subject, availability[i].checked)
+				}
+			}
+		} else {
+			Log.Debug("All %d checked articles available for file '%s'", availability[i].checked, file.Subject)
+		}
+	}
+
+	// the par2 index is used to classify the missing files and for the block size
+	if conf.Nzbcheck.Par2Check && result.Par2Index == nil {
+		if index, err := readPar2Index(result.Nzb, nil); err != nil {
+			Log.Warn("Unable to read the par2 file: %s", err.Error())
+		} else {
+			result.Par2Index = index
+		}
+	}
+
+	result.Verified = true
+	result.SegmentsUnavailable = estimated
+	result.FilesMissing += missingFiles
+	result.SegmentsMissing += estimated - missingFileSegments
+	evaluateResult(result)
+
+	if unavailable == 0 {
+		segmentsColor = green
+	} else if result.SegmentsComplete {
+		segmentsColor = yellow
+	} else {
+		segmentsColor = red
+	}
+	Log.Info("Articles: %s", segmentsColor(fmt.Sprintf("%d/%d available (Missing segments: %f %%)", checked-unavailable, checked, result.SegmentsMissingPercent)))
+	logPar2(*result)
+
+}
+
+// issues a STAT command for the (sampled) segments of each file using all configured connections
+func checkAvailability(result *Result) ([]fileAvailability, error) {
+
+	percent := conf.Nzbcheck.VerifySamplePercent
+	if percent <= 0 || percent > 100 {
+		percent = 100
+	}
+
+	// select the articles to check
+	var jobs []statJob
+	for i, file := range result.Nzb.Files {
+		indexes := rand.Perm(file.Segments.Len())
+		samples := int(math.Ceil(float64(file.Segments.Len()) * float64(percent) / 100))
+		for _, index := range indexes[:samples] {
+			jobs = append(jobs, statJob{file: i, messageID: file.Segments[index].Id})
+		}
+	}
+
+	// setup progress bar
+	bar := progressbar.NewOptions(len(jobs),
+		progressbar.OptionSetDescription("   Verifying ... "),
+		progressbar.OptionSetRenderBlankState(true),
+		progressbar.OptionThrottle(time.Millisecond*100),
+		progressbar.OptionShowElapsedTimeOnFinish(),
+		progressbar.OptionShowCount(),
+		progressbar.OptionUseANSICodes(conf.Directsearch.UseANSICodes),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobChan := make(chan statJob)
+	availability := make([]fileAvailability, result.Nzb.Files.Len())
+	var mutex sync.Mutex
+	var firstError error
+
+	var wg sync.WaitGroup
+	for range max(conf.Directsearch.Connections, 1) {
+		wg.Go(func() {
+			for job := range jobChan {
+				ok, err := statArticle(ctx, job.messageID)
+				mutex.Lock()
+				if err != nil {
+					if firstError == nil {
+						firstError = err
+						cancel()
+					}
+				} else {
+					availability[job.file].checked++
+					if !ok {
+						availability[job.file].unavailable++
+					}
+				}
+				mutex.Unlock()
+				bar.Add(1)
+			}
+		})
+	}
+
+feed:
+	for _, job := range jobs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobChan <- job:
+		}
+	}
+	close(jobChan)
+	wg.Wait()
+	bar.Finish()
+	fmt.Println()
+
+	if firstError != nil {
+		return nil, firstError
+	}
+	return availability, nil
+}
+
+// returns true if the article exists on the news server
+func statArticle(ctx context.Context, messageID string) (bool, error) {
+	pool, err := currentNntpPool()
+	if err != nil {
+		return false, err
+	}
+	var lastError error
+	for range 3 {
+		conn, err := pool.Get(ctx)
+		if err != nil {
+			return false, err
+		}
+		_, _, err = conn.Stat("<" + messageID + ">")
+		if err == nil {
+			pool.Put(conn)
+			return true, nil
+		}
+		var nntpError nntp.Error
+		if errors.As(err, &nntpError) {
+			pool.Put(conn)
+			if nntpError.Code == 430 || nntpError.Code == 423 {
+				return false, nil
+			}
+			return false, err
+		}
+		// connection error: discard the connection and try again
+		Log.Debug("Error while checking article <%s>: %s", messageID, err.Error())
+		conn.Close()
+		pool.Put(conn)
+		lastError = err
+	}
+	return false, lastError
+}
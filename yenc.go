@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// decoded yEnc part of an article
+type yencPart struct {
+	Name      string
+	Number    int
+	Size      int64 // size of the whole file
+	Begin     int64 // offset of the part in the file (starting at 1)
+	End       int64
+	Data      []byte
+	FileCRC   uint32 // crc32 of the whole file (0 if not provided)
+	CRCFailed bool   // true if the crc32 of the part did not match
+}
+
+// parses the key=value pairs of a yEnc header line
+// the name is always the last key and may contain spaces
+func parseYencHeader(line string) map[string]string {
+	values := make(map[string]string)
+	line, name, hasName := strings.Cut(line, " name=")
+	for field := range strings.FieldsSeq(line) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			values[key] = value
+		}
+	}
+	if hasName {
+		values["name"] = strings.TrimSpace(name)
+	}
+	return values
+}
+
+// parses a hexadecimal crc32 value of a yEnc trailer
+func parseYencCRC(value string) (uint32, bool) {
+	if value == "" {
+		return 0, false
+	}
+	crc, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(value), "0x"), 16, 32)
+	return uint32(crc), err == nil
+}
+
+// decodes the yEnc encoded body of an article
+// if headerOnly is true only the headers are parsed and no data is decoded
+func decodeYenc(r io.Reader, headerOnly bool) (*yencPart, error) {
+
+	part := &yencPart{}
+	reader := bufio.NewReader(r)
+	var data bytes.Buffer
+	begun := false
+	ended := false
+	var partCRC uint32
+	var hasPartCRC bool
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case bytes.HasPrefix(line, []byte("=ybegin ")):
+			header := parseYencHeader(string(line[8:]))
+			part.Name = header["name"]
+			part.Number, _ = strconv.Atoi(header["part"])
+			part.Size, _ = strconv.ParseInt(header["size"], 10, 64)
+			part.Begin, part.End = 1, part.Size
+			begun = true
+			if headerOnly && header["part"] == "" {
+				return part, nil
+			}
+		case bytes.HasPrefix(line, []byte("=ypart ")) && begun:
+			header := parseYencHeader(string(line[7:]))
+			part.Begin, _ = strconv.ParseInt(header["begin"], 10, 64)
+			part.End, _ = strconv.ParseInt(header["end"], 10, 64)
+			if headerOnly {
+				return part, nil
+			}
+		case bytes.HasPrefix(line, []byte("=yend")) && begun:
+			trailer := parseYencHeader(string(line[5:]))
+			if part.Number > 0 {
+				partCRC, hasPartCRC = parseYencCRC(trailer["pcrc32"])
+				part.FileCRC, _ = parseYencCRC(trailer["crc32"])
+			} else {
+				partCRC, hasPartCRC = parseYencCRC(trailer["crc32"])
+				part.FileCRC = partCRC
+			}
+			ended = true
+		case begun && !ended:
+			escaped := false
+			for _, b := range line {
+				if escaped {
+					data.WriteByte(b - 64 - 42)
+					escaped = false
+				} else if b == '=' {
+					escaped = true
+				} else {
+					data.WriteByte(b - 42)
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if !begun {
+		return nil, fmt.Errorf("no yEnc data found")
+	}
+	if headerOnly {
+		return part, nil
+	}
+	if !ended {
+		return nil, fmt.Errorf("yEnc data is incomplete")
+	}
+	part.Data = data.Bytes()
+	if hasPartCRC && crc32.ChecksumIEEE(part.Data) != partCRC {
+		part.CRCFailed = true
+	}
+	return part, nil
+
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// returns the yEnc encoded data with lines of 128 characters
+func testYencEncode(data []byte) string {
+	var encoded strings.Builder
+	column := 0
+	for _, b := range data {
+		b += 42
+		if b == 0 || b == '\n' || b == '\r' || b == '=' || (column == 0 && (b == '.' || b == ' ' || b == '\t')) {
+			encoded.WriteByte('=')
+			b += 64
+			column++
+		}
+		encoded.WriteByte(b)
+		column++
+		if column >= 128 {
+			encoded.WriteString("\r\n")
+			column = 0
+		}
+	}
+	if column > 0 {
+		encoded.WriteString("\r\n")
+	}
+	return encoded.String()
+}
+
+func TestDecodeYenc(t *testing.T) {
+
+	data := []byte("hello world\x00\n\r=\xd6\xe3\xe0.end")
+	crc := crc32.ChecksumIEEE(data)
+	part2 := []byte("second part")
+	fileCRC := crc32.ChecksumIEEE(append(append([]byte{}, data...), part2...))
+	size := len(data) + len(part2)
+
+	tests := []struct {
+		name       string
+		body       string
+		headerOnly bool
+		want       yencPart
+		wantData   []byte
+		wantErr    bool
+	}{
+		{
+			name:     "single part",
+			body:     fmt.Sprintf("=ybegin line=128 size=%d name=file.bin\r\n%s=yend size=%d crc32=%08x\r\n", len(data), testYencEncode(data), len(data), crc),
+			want:     yencPart{Name: "file.bin", Size: int64(len(data)), Begin: 1, End: int64(len(data)), FileCRC: crc},
+			wantData: data,
+		},
+		{
+			name:     "multipart",
+			body:     fmt.Sprintf("=ybegin part=1 total=2 line=128 size=%d name=file.bin\r\n=ypart begin=1 end=%d\r\n%s=yend size=%d part=1 pcrc32=%08x crc32=%08x\r\n", size, len(data), testYencEncode(data), len(data), crc, fileCRC),
+			want:     yencPart{Name: "file.bin", Number: 1, Size: int64(size), Begin: 1, End: int64(len(data)), FileCRC: fileCRC},
+			wantData: data,
+		},
+		{
+			name:     "name with spaces",
+			body:     fmt.Sprintf("=ybegin line=128 size=%d name= my file (1).bin \r\n%s=yend size=%d crc32=%08x\r\n", len(data), testYencEncode(data), len(data), crc),
+			want:     yencPart{Name: "my file (1).bin", Size: int64(len(data)), Begin: 1, End: int64(len(data)), FileCRC: crc},
+			wantData: data,
+		},
+		{
+			name:     "crc mismatch",
+			body:     fmt.Sprintf("=ybegin line=128 size=%d name=file.bin\r\n%s=yend size=%d crc32=%08x\r\n", len(data), testYencEncode(data), len(data), crc^1),
+			want:     yencPart{Name: "file.bin", Size: int64(len(data)), Begin: 1, End: int64(len(data)), FileCRC: crc ^ 1, CRCFailed: true},
+			wantData: data,
+		},
+		{
+			name:     "without crc",
+			body:     fmt.Sprintf("=ybegin line=128 size=%d name=file.bin\n%s=yend size=%d\n", len(data), testYencEncode(data), len(data)),
+			want:     yencPart{Name: "file.bin", Size: int64(len(data)), Begin: 1, End: int64(len(data))},
+			wantData: data,
+		},
+		{
+			name:     "article headers before the data",
+			body:     fmt.Sprintf("Subject: test\r\n\r\n=ybegin line=128 size=%d name=file.bin\r\n%s=yend size=%d crc32=%08X\r\n", len(data), testYencEncode(data), len(data), crc),
+			want:     yencPart{Name: "file.bin", Size: int64(len(data)), Begin: 1, End: int64(len(data)), FileCRC: crc},
+			wantData: data,
+		},
+		{
+			name:       "header only",
+			body:       fmt.Sprintf("=ybegin line=128 size=%d name=file.bin\r\n=y", size),
+			headerOnly: true,
+			want:       yencPart{Name: "file.bin", Size: int64(size), Begin: 1, End: int64(size)},
+		},
+		{
+			name:       "header only multipart",
+			body:       fmt.Sprintf("=ybegin part=2 total=2 line=128 size=%d name=file.bin\r\n=ypart begin=%d end=%d\r\n=y", size, len(data)+1, size),
+			headerOnly: true,
+			want:       yencPart{Name: "file.bin", Number: 2, Size: int64(size), Begin: int64(len(data) + 1), End: int64(size)},
+		},
+		{
+			name:    "missing trailer",
+			body:    fmt.Sprintf("=ybegin line=128 size=%d name=file.bin\r\n%s", len(data), testYencEncode(data)),
+			wantErr: true,
+		},
+		{
+			name:    "no yEnc data",
+			body:    "begin 644 file.bin\r\nM86)C\r\nend\r\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			part, err := decodeYenc(strings.NewReader(test.body), test.headerOnly)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got part %+v, want error", part)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %s", err.Error())
+			}
+			if !bytes.Equal(part.Data, test.wantData) {
+				t.Errorf("got data %q, want %q", part.Data, test.wantData)
+			}
+			part.Data = nil
+			if !reflect.DeepEqual(*part, test.want) {
+				t.Errorf("got part %+v, want %+v", *part, test.want)
+			}
+		})
+	}
+
+}
+
+func TestParseYencCRC(t *testing.T) {
+
+	tests := []struct {
+		value string
+		crc   uint32
+		ok    bool
+	}{
+		{"0a1b2c3d", 0x0a1b2c3d, true},
+		{"0A1B2C3D", 0x0a1b2c3d, true},
+		{"0x0a1b2c3d", 0x0a1b2c3d, true},
+		{"1b2c3d", 0x1b2c3d, true},
+		{"", 0, false},
+		{"xyz", 0, false},
+		{"10a1b2c3d", 0, false},
+	}
+
+	for _, test := range tests {
+		if crc, ok := parseYencCRC(test.value); ok != test.ok || (ok && crc != test.crc) {
+			t.Errorf("parseYencCRC(%q) = %08x, %t, want %08x, %t", test.value, crc, ok, test.crc, test.ok)
+		}
+	}
+
+}